@@ -0,0 +1,79 @@
+package analyse
+
+import (
+	"time"
+)
+
+// ageBucket is a range of how long ago files were modified or accessed.
+type ageBucket int
+
+const (
+	ageDay ageBucket = iota
+	ageWeek
+	ageMonth
+	ageYear
+	ageOlder
+)
+
+var ageBuckets = []ageBucket{ageDay, ageWeek, ageMonth, ageYear, ageOlder}
+
+const day = 24 * time.Hour
+
+// Months are 30 days, and years 365 days.
+var ageLimits = []time.Duration{day, 7 * day, 30 * day, 365 * day}
+
+func (b ageBucket) String() string {
+	switch b {
+	case ageDay:
+		return "< 1 day"
+	case ageWeek:
+		return "< 1 week"
+	case ageMonth:
+		return "< 1 month"
+	case ageYear:
+		return "< 1 year"
+	}
+	return "older"
+}
+
+func ageBucketOf(now time.Time, t time.Time) ageBucket {
+	age := now.Sub(t)
+	for i, limit := range ageLimits {
+		if age < limit {
+			return ageBuckets[i]
+		}
+	}
+	return ageOlder
+}
+
+// The files in an age bucket.
+type ageCount struct {
+	numFiles  int
+	size      int64
+	diskUsage int64
+}
+
+// ageHistogram counts files by how long ago something happened to them.
+type ageHistogram [ageOlder + 1]ageCount
+
+func (h *ageHistogram) add(now time.Time, t time.Time, size int64, diskUsage int64) {
+	c := &h[ageBucketOf(now, t)]
+	c.numFiles++
+	c.size += size
+	c.diskUsage += diskUsage
+}
+
+// staleFilter picks the files which are worth archiving: those last modified long enough ago, and
+// big enough.
+type staleFilter struct {
+	olderThan  time.Duration
+	largerThan int64
+}
+
+func (f staleFilter) enabled() bool {
+	return f.olderThan > 0 || f.largerThan > 0
+}
+
+func (f staleFilter) matches(now time.Time, modTime time.Time, usage int64) bool {
+	return now.Sub(modTime) >= f.olderThan && usage >= f.largerThan
+}
@@ -3,48 +3,437 @@ package analyse
 import (
 	"errors"
 	"fmt"
+	"github.com/robinmitra/forest/filter"
+	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/mount"
+	"github.com/robinmitra/forest/owner"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+	outputTSV  = "tsv"
 )
 
 type options struct {
 	includeDotFiles bool
-	root            string
+	apparentSize    bool
+	showErrors      bool
+	absolutePaths   bool
+	jobs            int
+	output          string
+	top             int
+	depth           int
+	sort            string
+	sortBy          sortKey
+	sectionNames    []string
+	sections        []section
+	filters         filter.Options
+	// The filter for what's below each root.
+	rootFilters map[string]*filter.Filter
+	from        string
+	scan        *scan.Reader
+	// A list of paths to analyse, rather than everything below the root, or - for stdin.
+	filesFrom string
+	// Whether the paths in the list are separated by NUL characters, rather than newlines.
+	null bool
+	// Whether to stay on the filesystem of each root, and the types of filesystems to leave out.
+	oneFileSystem bool
+	skipFSTypes   []string
+	// What keeps track of the mount points below each root.
+	guards map[string]*mount.Guard
+	// Whether to follow symbolic links, and to list the ones which are broken.
+	followSymlinks bool
+	brokenLinks    bool
+	// Which files to list as candidates for archiving, given as an age and a size.
+	olderThan  string
+	largerThan string
+	stale      staleFilter
+	// The user whose files are the only ones to analyse, if given, by name or ID.
+	owner   string
+	ownerID uint32
+	// The first path to analyse, and all of them when several were given.
+	root  string
+	roots []string
 }
 
 func (o *options) initialise(cmd *cobra.Command, args []string) {
 	if len(args) != 0 {
 		o.root = args[0]
+		o.roots = args
 	} else {
 		o.root = "."
+		o.roots = []string{o.root}
 	}
 	if includeDotFiles, _ := cmd.Flags().GetBool("include-dot-files"); includeDotFiles {
 		o.includeDotFiles = includeDotFiles
 	}
+	if apparentSize, _ := cmd.Flags().GetBool("apparent-size"); apparentSize {
+		o.apparentSize = apparentSize
+	}
+	if showErrors, _ := cmd.Flags().GetBool("show-errors"); showErrors {
+		o.showErrors = showErrors
+	}
+	if absolutePaths, _ := cmd.Flags().GetBool("absolute-paths"); absolutePaths {
+		o.absolutePaths = absolutePaths
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.jobs = jobs
+	}
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		o.output = output
+	}
+	if top, _ := cmd.Flags().GetInt("top"); top > 0 {
+		o.top = top
+	}
+	if depth, _ := cmd.Flags().GetInt("depth"); depth > 0 {
+		o.depth = depth
+	}
+	if sort, _ := cmd.Flags().GetString("sort"); sort != "" {
+		o.sort = sort
+	}
+	if sections, _ := cmd.Flags().GetStringSlice("sections"); len(sections) > 0 {
+		o.sectionNames = sections
+	}
+	if exclude, _ := cmd.Flags().GetStringArray("exclude"); len(exclude) > 0 {
+		o.filters.Exclude = exclude
+	}
+	if include, _ := cmd.Flags().GetStringArray("include"); len(include) > 0 {
+		o.filters.Include = include
+	}
+	if excludeFrom, _ := cmd.Flags().GetString("exclude-from"); excludeFrom != "" {
+		o.filters.ExcludeFrom = excludeFrom
+	}
+	if respectGitignore, _ := cmd.Flags().GetBool("respect-gitignore"); respectGitignore {
+		o.filters.RespectGitignore = respectGitignore
+	}
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		o.from = from
+	}
+	if filesFrom, _ := cmd.Flags().GetString("files-from"); filesFrom != "" {
+		o.filesFrom = filesFrom
+	}
+	if null, _ := cmd.Flags().GetBool("null"); null {
+		o.null = null
+	}
+	if oneFileSystem, _ := cmd.Flags().GetBool("one-file-system"); oneFileSystem {
+		o.oneFileSystem = oneFileSystem
+	}
+	if skipFSTypes, _ := cmd.Flags().GetStringSlice("skip-fs-types"); len(skipFSTypes) > 0 {
+		o.skipFSTypes = skipFSTypes
+	}
+	if followSymlinks, _ := cmd.Flags().GetBool("follow-symlinks"); followSymlinks {
+		o.followSymlinks = followSymlinks
+	}
+	if brokenLinks, _ := cmd.Flags().GetBool("broken-links"); brokenLinks {
+		o.brokenLinks = brokenLinks
+	}
+	if olderThan, _ := cmd.Flags().GetString("older-than"); olderThan != "" {
+		o.olderThan = olderThan
+	}
+	if largerThan, _ := cmd.Flags().GetString("larger-than"); largerThan != "" {
+		o.largerThan = largerThan
+	}
+	if owner, _ := cmd.Flags().GetString("owner"); owner != "" {
+		o.owner = owner
+	}
 }
 
 func (o *options) validate() {
-	if err := o.validatePath(os.Stat(o.root)); err != nil {
+	if o.filesFrom != "" && o.from != "" {
+		log.Fatal("Only one of --files-from and --from can be given")
+	}
+	if o.null && o.filesFrom == "" {
+		log.Fatal("--null only applies to the list of paths given with --files-from")
+	}
+	if len(o.roots) > 1 && (o.from != "" || o.filesFrom != "") {
+		log.Fatal("Only one path can be given along with --from or --files-from")
+	}
+	if o.from != "" {
+		// Work from the saved scan instead, without touching the filesystem.
+		r, err := scan.Open(o.from)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.scan = r
+		o.root = r.Root()
+		o.roots = []string{o.root}
+	} else {
+		for _, root := range o.roots {
+			_, err := os.Stat(root)
+			if err := validateRoot(root, err); err != nil {
+				log.Fatal(err)
+			}
+		}
+		// Roots inside other roots would be counted twice.
+		roots, overlaps := walker.DistinctRoots(o.roots)
+		for _, overlap := range overlaps {
+			log.Warnf("Leaving out %s, which is already included in %s", overlap.Path, overlap.Within)
+		}
+		o.root = roots[0]
+		o.roots = roots
+	}
+	if err := o.validateOutput(); err != nil {
+		log.Fatal(err)
+	}
+	if o.jobs < 1 {
+		log.Fatal("Number of jobs must be at least 1")
+	}
+	if o.top < 0 {
+		log.Fatal("Number of rows in each table can't be negative")
+	}
+	if o.depth < 0 {
+		log.Fatal("Depth of directories to rank can't be negative")
+	}
+	if o.sort != "" {
+		by, err := parseSortKey(o.sort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.sortBy = by
+	}
+	sections, err := parseSections(o.sectionNames)
+	if err != nil {
 		log.Fatal(err)
 	}
+	o.sections = sections
+	if o.olderThan != "" {
+		age, err := formatter.ParseAge(o.olderThan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.stale.olderThan = age
+	}
+	if o.largerThan != "" {
+		size, err := formatter.ParseStorage(o.largerThan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.stale.largerThan = size
+	}
+	if o.stale.enabled() && (o.output == outputCSV || o.output == outputTSV) {
+		log.Fatal("--older-than and --larger-than don't apply to csv and tsv output")
+	}
+	if o.owner != "" {
+		id, err := owner.LookupUser(o.owner)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.ownerID = id
+	}
+	// The filters are built up front, so that bad patterns are reported before walking.
+	o.rootFilters = make(map[string]*filter.Filter)
+	for _, root := range o.roots {
+		f, err := filter.New(root, o.filters)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.rootFilters[root] = f
+	}
+	if o.from == "" {
+		o.guards = newGuards(o.roots, o.oneFileSystem, o.skipFSTypes)
+	} else if o.oneFileSystem || len(o.skipFSTypes) > 0 {
+		log.Fatal("--one-file-system and --skip-fs-types don't apply to saved scans")
+	}
+}
+
+// newGuards returns what keeps track of the mount points below each root, and leaves out the ones
+// which aren't to be walked.
+func newGuards(roots []string, oneFileSystem bool, skipFSTypes []string) map[string]*mount.Guard {
+	mounts, err := mount.Load()
+	if err != nil {
+		// Device IDs are still compared, so walks can stay on one filesystem regardless.
+		log.Warn("Couldn't find out where filesystems are mounted: ", err)
+		mounts = mount.Table{}
+	}
+	guards := make(map[string]*mount.Guard)
+	for _, root := range roots {
+		g, err := mount.NewGuard(root, mounts, oneFileSystem, skipFSTypes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		guards[root] = g
+	}
+	return guards
 }
 
 func (o *options) validatePath(info os.FileInfo, err error) error {
+	return validateRoot(o.root, err)
+}
+
+func validateRoot(root string, err error) error {
 	if os.IsNotExist(err) {
-		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", o.root))
+		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", root))
 	}
 	return err
 }
 
+// rootPaths returns the paths to analyse, which is just the root unless several were given.
+func (o *options) rootPaths() []string {
+	if len(o.roots) == 0 {
+		return []string{o.root}
+	}
+	return o.roots
+}
+
+func (o *options) validateOutput() error {
+	switch o.output {
+	case outputText, outputJSON, outputCSV, outputTSV:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Unknown output format \"%s\"", o.output))
+}
+
+func (o *options) walkerOptions(root string) walker.Options {
+	opts := walker.Options{Jobs: o.jobs, IncludeDotFiles: o.includeDotFiles, FollowSymlinks: o.followSymlinks}
+	f, g := o.rootFilters[root], o.guards[root]
+	switch {
+	case f != nil && g != nil:
+		// Mount points which are excluded anyway aren't reported.
+		opts.Skip = func(path string, info os.FileInfo) bool {
+			return f.Skip(path, info) || g.Skip(path, info)
+		}
+	case f != nil:
+		opts.Skip = f.Skip
+	case g != nil:
+		opts.Skip = g.Skip
+	}
+	return opts
+}
+
+// walk calls fn for every file and directory to analyse below a root, whether on the filesystem,
+// in a saved scan or in a list of paths.
+func (o *options) walk(root string, fn filepath.WalkFunc) error {
+	if o.owner != "" {
+		fn = o.ownedOnly(fn)
+	}
+	if o.scan != nil {
+		return o.scan.Walk(o.walkerOptions(root), fn)
+	}
+	if o.filesFrom != "" {
+		return o.walkList(fn)
+	}
+	return walker.Walk(root, o.walkerOptions(root), fn)
+}
+
+// walkList calls fn for every path in the list given with --files-from, and the directories
+// leading up to them.
+func (o *options) walkList(fn filepath.WalkFunc) error {
+	var r io.Reader = os.Stdin
+	if o.filesFrom != "-" {
+		f, err := os.Open(o.filesFrom)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	sep := byte('\n')
+	if o.null {
+		sep = 0
+	}
+	return walker.WalkList(r, sep, o.walkerOptions(o.root), func(path string, info os.FileInfo, err error) error {
+		// Directories above the root aren't part of the analysis, even though the paths below them
+		// are listed.
+		if err == nil && info.IsDir() && isOutside(listRoot(o.root, path), path) {
+			return nil
+		}
+		return fn(path, info, err)
+	})
+}
+
+// ownedOnly leaves out the files which aren't owned by the user given with --owner, along with
+// those whose owner isn't known. Directories are still walked, since the user's files can be
+// anywhere below them.
+func (o *options) ownedOnly(fn filepath.WalkFunc) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if stat, ok := walker.StatOf(info); !ok || stat.Uid != o.ownerID {
+				return nil
+			}
+		}
+		return fn(path, info, err)
+	}
+}
+
+func isOutside(root string, path string) bool {
+	rel, err := relativeTo(root, path)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relativeTo returns path relative to root. Paths are made absolute first when only one of them
+// is, as they are by the filters.
+func relativeTo(root string, path string) (string, error) {
+	if filepath.IsAbs(root) != filepath.IsAbs(path) {
+		var err error
+		if root, err = filepath.Abs(root); err != nil {
+			return "", err
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Rel(root, path)
+}
+
+// listRoot returns the root which a listed path is below. When no path is given, absolute paths
+// aren't bounded by the working directory, but by the top of the filesystem, as with
+// `find /srv -print0 | forest analyse --files-from - --null`.
+func listRoot(root string, path string) string {
+	if root == "." && filepath.IsAbs(path) {
+		return filepath.VolumeName(path) + string(filepath.Separator)
+	}
+	return root
+}
+
 func (o *options) run() {
-	log.Info("Analysing directory:", o.root)
-	summary := process(o.root, o.includeDotFiles)
-	summary.print()
+	log.Info("Analysing directories:", strings.Join(o.roots, ", "))
+	if o.scan != nil {
+		defer o.scan.Close()
+	}
+	if o.output == outputCSV || o.output == outputTSV {
+		// Rows are written as the tree is walked, without a summary at the end.
+		o.runRecords()
+		return
+	}
+	if o.output == outputJSON {
+		// Progress updates are left out entirely, so that the document can be piped into other
+		// tools, even when there is no terminal attached.
+		summary := process(o, ioutil.Discard)
+		if err := summary.printJSON(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		exitIfPartial(summary)
+		return
+	}
+	writer := newProgressWriter()
+	writer.Start() // Start listening for updates and render.
+	summary := process(o, writer)
+	writer.Stop()
+	summary.print(o.showErrors)
+	exitIfPartial(summary)
+}
+
+// Exits with a distinct code when some paths were skipped, so that scripts can tell that the
+// results are incomplete.
+func exitIfPartial(s summary) {
+	if s.partial() {
+		os.Exit(walker.ExitCodePartial)
+	}
 }
 
 var cmd = &cobra.Command{
-	Use:   "analyse [path]",
+	Use:   "analyse [path...]",
 	Short: "Analyse directories and files",
 }
 
@@ -64,6 +453,148 @@ func NewAnalyseCmd() *cobra.Command {
 		false,
 		"include hidden files (default is false)",
 	)
+	cmd.Flags().BoolVar(
+		&o.apparentSize,
+		"apparent-size",
+		false,
+		"use apparent sizes rather than disk usage for totals and sorting",
+	)
+	cmd.Flags().BoolVar(
+		&o.showErrors,
+		"show-errors",
+		false,
+		"list the paths which couldn't be read",
+	)
+	cmd.Flags().BoolVar(
+		&o.absolutePaths,
+		"absolute-paths",
+		false,
+		"show absolute paths, rather than paths relative to the analysed path",
+	)
+	cmd.Flags().StringVar(
+		&o.from,
+		"from",
+		"",
+		"analyse a scan saved by the snapshot command, rather than the filesystem",
+	)
+	cmd.Flags().StringVar(
+		&o.filesFrom,
+		"files-from",
+		"",
+		"analyse the paths listed in a file, or - for stdin, rather than everything below a path",
+	)
+	cmd.Flags().BoolVar(
+		&o.null,
+		"null",
+		false,
+		"paths given with --files-from are separated by NUL characters, rather than newlines",
+	)
+	cmd.Flags().BoolVarP(
+		&o.oneFileSystem,
+		"one-file-system",
+		"x",
+		false,
+		"stay on the filesystem of each path, rather than crossing into other mounted filesystems",
+	)
+	cmd.Flags().StringSliceVar(
+		&o.skipFSTypes,
+		"skip-fs-types",
+		nil,
+		"leave out filesystems of these types, such as nfs,fuse",
+	)
+	cmd.Flags().BoolVarP(
+		&o.followSymlinks,
+		"follow-symlinks",
+		"L",
+		false,
+		"follow symbolic links, and count what they point to rather than the links themselves",
+	)
+	cmd.Flags().BoolVar(
+		&o.brokenLinks,
+		"broken-links",
+		false,
+		"list the symbolic links which point to nothing, along with their targets",
+	)
+	cmd.Flags().StringVar(
+		&o.olderThan,
+		"older-than",
+		"",
+		"list files last modified at least this long ago, such as 180d, 2w or 1y, to archive",
+	)
+	cmd.Flags().StringVar(
+		&o.largerThan,
+		"larger-than",
+		"",
+		"list files of at least this size, such as 100M, to archive",
+	)
+	cmd.Flags().StringVar(
+		&o.owner,
+		"owner",
+		"",
+		"only analyse the files owned by this user, given by name or ID",
+	)
+	cmd.Flags().IntVarP(
+		&o.jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories to read concurrently",
+	)
+	cmd.Flags().StringVarP(
+		&o.output,
+		"output",
+		"o",
+		outputText,
+		"output format of the summary (text or json), or csv or tsv for a row per file and directory",
+	)
+	cmd.Flags().IntVar(
+		&o.top,
+		"top",
+		5,
+		"number of rows in each table of the summary, or 0 for all of them",
+	)
+	cmd.Flags().IntVar(
+		&o.depth,
+		"depth",
+		0,
+		"only rank directories up to this many levels below the path, or 0 for all of them",
+	)
+	cmd.Flags().StringVar(
+		&o.sort,
+		"sort",
+		"",
+		"sort the tables of the summary by size, count, name, mtime, path or owner",
+	)
+	cmd.Flags().StringSliceVar(
+		&o.sectionNames,
+		"sections",
+		[]string{"extensions", "files", "directories", "ages", "sizes", "owners"},
+		"sections of the summary to print (files, extensions, directories, ages, sizes and owners)",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Exclude,
+		"exclude",
+		nil,
+		"exclude files and directories matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Include,
+		"include",
+		nil,
+		"only include files matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringVar(
+		&o.filters.ExcludeFrom,
+		"exclude-from",
+		"",
+		"exclude files and directories matching the glob patterns listed in a file",
+	)
+	cmd.Flags().BoolVar(
+		&o.filters.RespectGitignore,
+		"respect-gitignore",
+		false,
+		"exclude files and directories ignored by .gitignore files",
+	)
 
 	return cmd
 }
@@ -3,10 +3,15 @@ package analyse
 import (
 	"bytes"
 	"errors"
+	"github.com/robinmitra/forest/filter"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,12 +19,14 @@ import (
 type fileInfoMock struct {
 	dir      bool
 	basename string
+	size     int64
+	modTime  time.Time
 }
 
 func (f fileInfoMock) Name() string       { return f.basename }
-func (f fileInfoMock) ModTime() time.Time { return time.Time{} }
+func (f fileInfoMock) ModTime() time.Time { return f.modTime }
 func (f fileInfoMock) IsDir() bool        { return f.dir }
-func (f fileInfoMock) Size() int64        { return int64(0) }
+func (f fileInfoMock) Size() int64        { return f.size }
 func (f fileInfoMock) Mode() os.FileMode {
 	if f.dir {
 		return 0755 | os.ModeDir
@@ -64,8 +71,11 @@ func TestAnalysisOfPath(t *testing.T) {
 		var writer bytes.Buffer
 		walkFunc := processFile(&analysis, true, &writer)
 		err := walkFunc("some-path", info, errors.New("something went wrong"))
-		if err == nil {
-			t.Errorf("Expected error to be returned when there is a problem walking a path.")
+		if err != nil {
+			t.Errorf("Expected walking to carry on when there is a problem walking a path.")
+		}
+		if len(analysis.skipped.Paths) != 1 || analysis.skipped.Paths[0].Path != "some-path" {
+			t.Errorf("Expected path to be reported as skipped when there is a problem walking it.")
 		}
 	})
 	t.Run("Path is current directory", func(t *testing.T) {
@@ -155,3 +165,394 @@ func TestAnalysisOfPath(t *testing.T) {
 		}
 	})
 }
+
+// createTree creates a temporary directory holding files of the given sizes, by path.
+func createTree(t *testing.T, sizes map[string]int) string {
+	root, err := ioutil.TempDir("", "forest-analyse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, size := range sizes {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestHardLinksAreCountedOnce(t *testing.T) {
+	dir := createTree(t, map[string]int{"original.bin": 1000})
+	defer os.RemoveAll(dir)
+	original := filepath.Join(dir, "original.bin")
+	for _, name := range []string{"link1.bin", "link2.bin"} {
+		if err := os.Link(original, filepath.Join(dir, name)); err != nil {
+			t.Skipf("Hard links are not supported: %s", err)
+		}
+	}
+
+	o := options{root: dir, jobs: 2, apparentSize: true}
+	summary := process(&o, ioutil.Discard)
+
+	if summary.numFiles != 3 {
+		t.Errorf("Expected every link to be counted as a file, found %d files", summary.numFiles)
+	}
+	if summary.size != 1000 {
+		t.Errorf("Expected the linked file to be counted once, found %d bytes", summary.size)
+	}
+	if summary.numHardLinks != 3 || summary.analysis.hardLinksSize != 2000 {
+		t.Errorf(
+			"Expected 3 hard links saving 2000 bytes, found %d saving %d bytes",
+			summary.numHardLinks,
+			summary.analysis.hardLinksSize,
+		)
+	}
+}
+
+func TestDirectoriesIncludeEverythingInside(t *testing.T) {
+	analysis := newAnalysis()
+	analysis.root = "/data"
+	analysis.apparentSize = true
+	var writer bytes.Buffer
+	walkFunc := processFile(&analysis, false, &writer)
+	entries := []struct {
+		path string
+		info fileInfoMock
+	}{
+		{"/data", fileInfoMock{dir: true, basename: "data"}},
+		{"/data/a", fileInfoMock{dir: true, basename: "a"}},
+		{"/data/a/file1", fileInfoMock{basename: "file1", size: 100}},
+		{"/data/a/b", fileInfoMock{dir: true, basename: "b"}},
+		{"/data/a/b/file2", fileInfoMock{basename: "file2", size: 400}},
+		{"/data/c", fileInfoMock{dir: true, basename: "c"}},
+		{"/data/c/file3", fileInfoMock{basename: "file3", size: 300}},
+	}
+	for _, e := range entries {
+		if err := walkFunc(e.path, e.info, nil); err != nil {
+			t.Fatalf("Unexpected error processing %s: %s", e.path, err)
+		}
+	}
+
+	directories := analysis.getSortedDirectories(sortBySize, 0, 0)
+	expected := []directory{
+		{name: "a", path: "a", depth: 1, numFiles: 2, size: 500, diskUsage: 500},
+		{name: "b", path: filepath.Join("a", "b"), depth: 2, numFiles: 1, size: 400, diskUsage: 400},
+		{name: "c", path: "c", depth: 1, numFiles: 1, size: 300, diskUsage: 300},
+	}
+	if !reflect.DeepEqual(directories, expected) {
+		t.Errorf("Expected directories to be %+v, found %+v", expected, directories)
+	}
+
+	directories = analysis.getSortedDirectories(sortBySize, 1, 0)
+	if len(directories) != 2 || directories[0].path != "a" || directories[1].path != "c" {
+		t.Errorf("Expected only the top level directories to be ranked, found %+v", directories)
+	}
+}
+
+func TestFilesCarryPathsAndMetadata(t *testing.T) {
+	dir := createTree(t, map[string]int{"sub/data.bin": 10})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "data.bin")
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	o := options{root: dir, jobs: 1}
+	f := process(&o, ioutil.Discard).analysis.files[0]
+	if f.path != filepath.Join("sub", "data.bin") {
+		t.Errorf("Expected path relative to the root, found %s", f.path)
+	}
+	if f.mode != 0640 || f.modTime.IsZero() || f.owner == "" {
+		t.Errorf("Expected mode, modification time and owner to be recorded, found %+v", f)
+	}
+
+	o = options{root: dir, jobs: 1, absolutePaths: true}
+	f = process(&o, ioutil.Discard).analysis.files[0]
+	if f.path != path {
+		t.Errorf("Expected absolute path %s, found %s", path, f.path)
+	}
+}
+
+func TestFilesFromList(t *testing.T) {
+	dir := createTree(t, map[string]int{
+		"a/b/one.txt":    100,
+		"a/two.md":       100,
+		"c/three.txt":    100,
+		"c/unlisted.txt": 100,
+	})
+	defer os.RemoveAll(dir)
+	list := filepath.Join(dir, "list")
+	paths := filepath.Join(dir, "a/b/one.txt") + "\x00" + filepath.Join(dir, "a/two.md") + "\x00" +
+		filepath.Join(dir, "c/three.txt") + "\x00"
+	if err := ioutil.WriteFile(list, []byte(paths), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := options{root: dir, jobs: 1, apparentSize: true, filesFrom: list, null: true}
+	summary := process(&o, ioutil.Discard)
+
+	if summary.numFiles != 3 || summary.size != 300 {
+		t.Errorf("Expected only the 3 listed files, found %d files of %d bytes", summary.numFiles, summary.size)
+	}
+	if ext := summary.analysis.extensions[".txt"]; ext.numFiles != 2 {
+		t.Errorf("Expected 2 .txt files, found %d", ext.numFiles)
+	}
+	var dirs []string
+	for _, d := range summary.analysis.getSortedDirectories(sortByPath, 0, 0) {
+		dirs = append(dirs, d.path+":"+strconv.Itoa(d.numFiles))
+	}
+	expected := []string{"a:2", "a/b:1", "c:1"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected directories %v, found %v", expected, dirs)
+	}
+}
+
+func TestFilesFromAbsoluteListWithoutRoot(t *testing.T) {
+	dir := createTree(t, map[string]int{"a/b/one.txt": 100, "c/two.txt": 200})
+	defer os.RemoveAll(dir)
+	var paths string
+	for _, p := range []string{"", "a", "a/b", "a/b/one.txt", "c", "c/two.txt"} {
+		paths += filepath.Join(dir, p) + "\n"
+	}
+	list := filepath.Join(dir, "list")
+	if err := ioutil.WriteFile(list, []byte(paths), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No path is given, as with `find /srv -print0 | forest analyse --files-from - --null`.
+	o := options{root: ".", roots: []string{"."}, jobs: 1, apparentSize: true, filesFrom: list}
+	summary := process(&o, ioutil.Discard)
+
+	// The directories leading up to the temporary directory are counted too, from the top of the
+	// filesystem.
+	above := strings.Count(filepath.Clean(dir), string(filepath.Separator))
+	if summary.numDirectories != above+4 {
+		t.Errorf("Expected %d directories, found %d", above+4, summary.numDirectories)
+	}
+	sizes := make(map[string]string)
+	for _, d := range summary.analysis.getSortedDirectories(sortByPath, 0, 0) {
+		sizes[d.path] = strconv.Itoa(d.numFiles) + ":" + strconv.FormatInt(d.size, 10)
+	}
+	expected := map[string]string{dir: "2:300", filepath.Join(dir, "a"): "1:100", filepath.Join(dir, "a", "b"): "1:100"}
+	for path, size := range expected {
+		if sizes[path] != size {
+			t.Errorf("Expected %s to hold %s, found %q", path, size, sizes[path])
+		}
+	}
+}
+
+func TestFilesFromListOfAbsolutePathsAreFiltered(t *testing.T) {
+	dir := createTree(t, map[string]int{"one.txt": 100, "two.md": 100})
+	defer os.RemoveAll(dir)
+	paths := filepath.Join(dir, "one.txt") + "\n" + filepath.Join(dir, "two.md") + "\n"
+	list := filepath.Join(dir, "list")
+	if err := ioutil.WriteFile(list, []byte(paths), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The listed paths are absolute, whereas the root is the working directory, as it is by default.
+	o := options{
+		root:         ".",
+		roots:        []string{"."},
+		jobs:         1,
+		output:       outputText,
+		apparentSize: true,
+		filesFrom:    list,
+		filters:      filter.Options{Exclude: []string{"*.md"}},
+	}
+	o.validate()
+	summary := process(&o, ioutil.Discard)
+
+	if summary.numFiles != 1 || summary.size != 100 {
+		t.Errorf("Expected two.md to be excluded, found %d files of %d bytes", summary.numFiles, summary.size)
+	}
+}
+
+func TestSeveralRoots(t *testing.T) {
+	var roots []string
+	for i := 1; i <= 2; i++ {
+		dir := createTree(t, map[string]int{"sub/data.bin": 100 * i})
+		defer os.RemoveAll(dir)
+		roots = append(roots, dir)
+	}
+
+	o := options{root: roots[0], roots: roots, jobs: 1, apparentSize: true}
+	summary := process(&o, ioutil.Discard)
+
+	if summary.numFiles != 2 || summary.size != 300 {
+		t.Errorf("Expected both roots to be counted, found %d files of %d bytes", summary.numFiles, summary.size)
+	}
+	totals := summary.analysis.roots
+	if len(totals) != 2 || totals[0].path != roots[0] || totals[0].size != 100 || totals[1].size != 200 {
+		t.Errorf("Expected a total for each root, found %+v", totals)
+	}
+	if f := summary.analysis.files[1]; f.path != filepath.Join(roots[1], "sub", "data.bin") {
+		t.Errorf("Expected file path to start with its root, found %s", f.path)
+	}
+}
+
+func TestSymlinksAreCounted(t *testing.T) {
+	dir := createTree(t, map[string]int{"data.bin": 100})
+	defer os.RemoveAll(dir)
+	for link, target := range map[string]string{"good": "data.bin", "bad": "missing.bin"} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("Symbolic links are not supported: %s", err)
+		}
+	}
+
+	o := options{root: dir, jobs: 1, apparentSize: true}
+	a := process(&o, ioutil.Discard).analysis
+	if a.numSymlinks != 2 {
+		t.Errorf("Expected 2 symbolic links, found %d", a.numSymlinks)
+	}
+	expected := []brokenLink{{path: "bad", target: "missing.bin"}}
+	if !reflect.DeepEqual(a.brokenLinks, expected) {
+		t.Errorf("Expected broken links %+v, found %+v", expected, a.brokenLinks)
+	}
+
+	o = options{root: dir, jobs: 1, apparentSize: true, followSymlinks: true}
+	s := process(&o, ioutil.Discard)
+	if s.analysis.numSymlinks != 2 || len(s.analysis.brokenLinks) != 1 {
+		t.Errorf("Expected links to be counted when followed too, found %d", s.analysis.numSymlinks)
+	}
+	// The link to data.bin is followed to data.bin, which has been counted already, and the broken
+	// link is only counted as a link.
+	if s.numFiles != 2 || s.size != 100 {
+		t.Errorf("Expected the file the link points to to be counted once, found %d bytes", s.size)
+	}
+}
+
+func TestLinksWhichCantBeFollowedAreNotFiles(t *testing.T) {
+	dir := createTree(t, map[string]int{"a/b/data.bin": 100})
+	defer os.RemoveAll(dir)
+	for link, target := range map[string]string{"a/b/up": "..", "c/broken": "nowhere"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, link)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("Symbolic links are not supported: %s", err)
+		}
+	}
+
+	o := options{root: dir, jobs: 1, apparentSize: true, followSymlinks: true}
+	s := process(&o, ioutil.Discard)
+	if s.numFiles != 1 || s.size != 100 || s.analysis.sizes[sizeEmpty].numFiles != 0 {
+		t.Errorf("Expected only data.bin to be counted as a file, found %d files of %d bytes", s.numFiles, s.size)
+	}
+	if _, ok := s.analysis.extensions["(missing)"]; ok {
+		t.Errorf("Expected the links not to be counted as files without an extension")
+	}
+	if s.analysis.numSymlinks != 2 || len(s.analysis.brokenLinks) != 1 {
+		t.Errorf(
+			"Expected 2 links, 1 of them broken, found %d and %d",
+			s.analysis.numSymlinks,
+			len(s.analysis.brokenLinks),
+		)
+	}
+}
+
+func TestFilesAreBucketedByAge(t *testing.T) {
+	analysis := newAnalysis()
+	analysis.root = "/data"
+	analysis.apparentSize = true
+	now := analysis.now
+	walkFunc := processFile(&analysis, false, ioutil.Discard)
+	entries := []struct {
+		path string
+		info fileInfoMock
+	}{
+		{"/data", fileInfoMock{dir: true, basename: "data"}},
+		{"/data/new", fileInfoMock{basename: "new", size: 1, modTime: now.Add(-time.Hour)}},
+		{"/data/week", fileInfoMock{basename: "week", size: 10, modTime: now.Add(-3 * day)}},
+		{"/data/year", fileInfoMock{basename: "year", size: 100, modTime: now.Add(-200 * day)}},
+		{"/data/old", fileInfoMock{basename: "old", size: 1000, modTime: now.Add(-400 * day)}},
+		{"/data/older", fileInfoMock{basename: "older", size: 5, modTime: now.Add(-500 * day)}},
+	}
+	for _, e := range entries {
+		if err := walkFunc(e.path, e.info, nil); err != nil {
+			t.Fatalf("Unexpected error processing %s: %s", e.path, err)
+		}
+	}
+
+	expected := ageHistogram{
+		ageDay:   {numFiles: 1, size: 1, diskUsage: 1},
+		ageWeek:  {numFiles: 1, size: 10, diskUsage: 10},
+		ageYear:  {numFiles: 1, size: 100, diskUsage: 100},
+		ageOlder: {numFiles: 2, size: 1005, diskUsage: 1005},
+	}
+	if analysis.modified != expected {
+		t.Errorf("Expected files to be bucketed as %+v, found %+v", expected, analysis.modified)
+	}
+	if analysis.hasAtime {
+		t.Errorf("Expected access times to be unknown")
+	}
+
+	var paths []string
+	for _, f := range analysis.getStaleFiles(staleFilter{olderThan: 180 * day, largerThan: 10}) {
+		paths = append(paths, f.path)
+	}
+	if !reflect.DeepEqual(paths, []string{"old", "year"}) {
+		t.Errorf("Expected old and large files, largest first, found %v", paths)
+	}
+}
+
+func TestAccessTimesAreBucketed(t *testing.T) {
+	dir := createTree(t, map[string]int{"data.bin": 10})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.bin")
+	accessed := time.Now().Add(-10 * day)
+	if err := os.Chtimes(path, accessed, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	o := options{root: dir, jobs: 1}
+	analysis := process(&o, ioutil.Discard).analysis
+	if !analysis.hasAtime {
+		t.Skip("Access times are not supported")
+	}
+	if analysis.accessed[ageMonth].numFiles != 1 || analysis.modified[ageDay].numFiles != 1 {
+		t.Errorf(
+			"Expected the file to be read within a month and modified within a day, found %+v and %+v",
+			analysis.accessed,
+			analysis.modified,
+		)
+	}
+}
+
+func TestUsageByOwner(t *testing.T) {
+	analysis := newAnalysis()
+	analysis.apparentSize = true
+	analysis.registerOwner(1000, 100, 300, 4096)
+	analysis.registerOwner(1001, 100, 500, 4096)
+	analysis.registerOwner(1000, 100, 400, 4096)
+
+	users := analysis.getSortedOwners(analysis.users, sortBySize, 0)
+	if len(users) != 2 || users[0].id != 1000 || users[0].numFiles != 2 || users[0].size != 700 {
+		t.Errorf("Expected user 1000 to own two files of 700 bytes first, found %+v", users)
+	}
+	groups := analysis.getSortedOwners(analysis.groups, sortByCount, 1)
+	if len(groups) != 1 || groups[0].numFiles != 3 || groups[0].size != 1200 {
+		t.Errorf("Expected the group to own every file, found %+v", groups)
+	}
+}
+
+func TestOnlyFilesOfTheOwnerAreAnalysed(t *testing.T) {
+	dir := createTree(t, map[string]int{"data.bin": 10})
+	defer os.RemoveAll(dir)
+
+	o := options{root: dir, jobs: 1, owner: strconv.Itoa(os.Getuid()), ownerID: uint32(os.Getuid())}
+	if summary := process(&o, ioutil.Discard); summary.numFiles != 1 || len(summary.analysis.users) != 1 {
+		t.Errorf("Expected the file of the current user to be analysed, found %d files", summary.numFiles)
+	}
+	o.ownerID++
+	if summary := process(&o, ioutil.Discard); summary.numFiles != 0 || summary.numDirectories != 1 {
+		t.Errorf(
+			"Expected only the root directory to be left, found %d files and %d directories",
+			summary.numFiles,
+			summary.numDirectories,
+		)
+	}
+}
@@ -1,30 +1,162 @@
 package analyse
 
-import "sort"
+import (
+	"github.com/robinmitra/forest/mount"
+	"github.com/robinmitra/forest/owner"
+	"github.com/robinmitra/forest/walker"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
 
 type file struct {
 	name string
-	size int64
+	// Path relative to the root being analysed, or absolute if asked for.
+	path      string
+	size      int64
+	diskUsage int64
+	modTime   time.Time
+	// When the file was last read, which is zero if it isn't known.
+	accessTime time.Time
+	mode       os.FileMode
+	uid        uint32
+	// Name of the user who owns the file, which is empty when it isn't known.
+	owner string
 }
 
+// A directory, along with everything inside it.
 type directory struct {
 	name string
+	// Path relative to the root being analysed, or absolute if asked for.
+	path string
+	// How far below the root the directory is, where the root itself is 0.
+	depth     int
+	numFiles  int
+	size      int64
+	diskUsage int64
+	modTime   time.Time
+	owner     string
+}
+
+// A symbolic link which points to nothing.
+type brokenLink struct {
+	path   string
+	target string
 }
 
 type extension struct {
 	name      string
 	numFiles  int
+	size      int64
 	diskUsage int64
+	// When the most recently modified file with the extension was modified.
+	modTime time.Time
+}
+
+// The totals for one of the roots being analysed.
+type rootTotal struct {
+	path           string
+	numFiles       int
+	numDirectories int
+	size           int64
+	diskUsage      int64
 }
 
 type analysis struct {
+	// The root being walked, and the totals for each root once it has been walked.
+	root  string
+	roots []rootTotal
+	// Whether paths are absolute, rather than relative to the root.
+	absolutePaths bool
+	// Whether paths start with the root they're in, since there are several of them.
+	prefixRoots bool
+	owners      *owner.Names
 	files       []file
 	directories []directory
-	diskUsage   int64
-	extensions  map[string]extension
+	// Index of each directory in directories, by the path it was walked as.
+	directoryIndex map[string]int
+	size           int64
+	diskUsage      int64
+	extensions     map[string]extension
+	// Hard-linked files, and the bytes saved by counting each of them only once.
+	links              *walker.Links
+	numHardLinks       int
+	hardLinksSize      int64
+	hardLinksDiskUsage int64
+	// Paths which couldn't be read, and were left out.
+	skipped walker.Skipped
+	// Mount points which were walked into, or left out.
+	mounts []mount.Crossing
+	// Symbolic links, and the ones which are broken, if links can be checked at all.
+	numSymlinks int
+	brokenLinks []brokenLink
+	checkLinks  bool
+	// Whether links are followed, so that the only links left are those which can't be.
+	followSymlinks bool
+	// When the analysis started, which is what the ages of files are measured from.
+	now time.Time
+	// Files by how long ago they were modified, and read if that's known for any of them.
+	modified ageHistogram
+	accessed ageHistogram
+	hasAtime bool
+	// Files by their apparent size.
+	sizes sizeHistogram
+	// Files by the user and the group which own them.
+	users  map[uint32]ownerUsage
+	groups map[uint32]ownerUsage
+	// Whether the apparent size, rather than the disk usage, drives the totals and sorting.
+	apparentSize bool
 }
 
-func (a *analysis) registerExtension(extName string, size int64) {
+// usage returns either the apparent size or the disk usage, depending on which one was asked for.
+func (a *analysis) usage(size int64, diskUsage int64) int64 {
+	if a.apparentSize {
+		return size
+	}
+	return diskUsage
+}
+
+// walkRoot calls walk to walk one of the roots, and keeps track of how much was found below it.
+func (a *analysis) walkRoot(root string, walk func(root string) error) error {
+	a.root = root
+	files, directories, size, diskUsage := len(a.files), len(a.directories), a.size, a.diskUsage
+	err := walk(root)
+	a.roots = append(a.roots, rootTotal{
+		path:           root,
+		numFiles:       len(a.files) - files,
+		numDirectories: len(a.directories) - directories,
+		size:           a.size - size,
+		diskUsage:      a.diskUsage - diskUsage,
+	})
+	return err
+}
+
+// registerAge counts a file towards the age histograms.
+func (a *analysis) registerAge(f file) {
+	a.modified.add(a.now, f.modTime, f.size, f.diskUsage)
+	if !f.accessTime.IsZero() {
+		a.hasAtime = true
+		a.accessed.add(a.now, f.accessTime, f.size, f.diskUsage)
+	}
+}
+
+// getStaleFiles returns the files which match the filter, largest first.
+func (a *analysis) getStaleFiles(stale staleFilter) []file {
+	var files []file
+	for _, f := range a.files {
+		if stale.matches(a.now, f.modTime, a.usage(f.size, f.diskUsage)) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return before(sortBySize, a.fileRank(files[i]), a.fileRank(files[j]))
+	})
+	return files
+}
+
+func (a *analysis) registerExtension(extName string, size int64, diskUsage int64, modTime time.Time) {
 	if len(extName) == 0 {
 		extName = "(missing)"
 	}
@@ -34,23 +166,99 @@ func (a *analysis) registerExtension(extName string, size int64) {
 	}
 	ext.name = extName
 	ext.numFiles++
-	ext.diskUsage = +size
+	ext.size += size
+	ext.diskUsage += diskUsage
+	if modTime.After(ext.modTime) {
+		ext.modTime = modTime
+	}
 	a.extensions[extName] = ext
 }
-func (a *analysis) getSortedExtensions(by string, count int) []extension {
+
+// displayPath returns the path to show for a file or directory that was walked as path.
+func (a *analysis) displayPath(path string) string {
+	if a.absolutePaths {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+	if a.prefixRoots {
+		return filepath.Clean(path)
+	}
+	if rel, err := filepath.Rel(a.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// accessTimeOf returns when a file was last read, or zero if it isn't known.
+func accessTimeOf(info os.FileInfo) time.Time {
+	if stat, ok := walker.StatOf(info); ok {
+		return stat.Atime
+	}
+	return time.Time{}
+}
+
+// ownerOf returns the ID and name of the user who owns a file, if it's known.
+func (a *analysis) ownerOf(info os.FileInfo) (uint32, string) {
+	stat, ok := walker.StatOf(info)
+	if !ok {
+		return 0, ""
+	}
+	return stat.Uid, a.owners.User(stat.Uid)
+}
+
+// registerLink counts a symbolic link, whether it was followed or not, and checks whether it's
+// broken.
+func (a *analysis) registerLink(path string, info os.FileInfo) {
+	a.numSymlinks++
+	if !a.checkLinks || walker.IsFollowed(info) {
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		target = ""
+	}
+	a.brokenLinks = append(a.brokenLinks, brokenLink{path: a.displayPath(path), target: target})
+}
+
+func (a *analysis) registerDirectory(path string, dir directory) {
+	dir.path = a.displayPath(path)
+	if rel, err := relativeTo(listRoot(a.root, path), path); err == nil && rel != "." {
+		dir.depth = strings.Count(rel, string(filepath.Separator)) + 1
+	}
+	a.directoryIndex[path] = len(a.directories)
+	a.directories = append(a.directories, dir)
+}
+
+// addToDirectories counts a file towards the directory it's in, and all of the directories above
+// that one.
+func (a *analysis) addToDirectories(path string, size int64, diskUsage int64) {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		i, ok := a.directoryIndex[dir]
+		if !ok {
+			return
+		}
+		a.directories[i].numFiles++
+		a.directories[i].size += size
+		a.directories[i].diskUsage += diskUsage
+		if dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+func (a *analysis) getSortedExtensions(by sortKey, count int) []extension {
 	var extensions []extension
 	for _, ext := range a.extensions {
 		extensions = append(extensions, ext)
 	}
-	if by == "occurrence" {
-		sort.Slice(extensions, func(i, j int) bool {
-			return extensions[i].numFiles > extensions[j].numFiles
-		})
-	} else {
-		sort.Slice(extensions, func(i, j int) bool {
-			return extensions[i].diskUsage > extensions[j].diskUsage
-		})
-	}
+	sort.Slice(extensions, func(i, j int) bool {
+		return before(by, a.extensionRank(extensions[i]), a.extensionRank(extensions[j]))
+	})
 	if count > 0 {
 		if len(extensions) > count {
 			return extensions[0:count]
@@ -59,14 +267,12 @@ func (a *analysis) getSortedExtensions(by string, count int) []extension {
 	return extensions
 }
 
-func (a *analysis) getSortedFiles(by string, count int) []file {
+func (a *analysis) getSortedFiles(by sortKey, count int) []file {
 	files := make([]file, len(a.files))
 	copy(files, a.files)
-	if by == "size" {
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].size > files[j].size
-		})
-	}
+	sort.Slice(files, func(i, j int) bool {
+		return before(by, a.fileRank(files[i]), a.fileRank(files[j]))
+	})
 	if count > 0 {
 		if len(files) > count {
 			return files[0:count]
@@ -75,8 +281,65 @@ func (a *analysis) getSortedFiles(by string, count int) []file {
 	return files
 }
 
+// getSortedDirectories ranks the directories below the root, down to the given depth, or all of
+// them if depth is zero.
+func (a *analysis) getSortedDirectories(by sortKey, depth int, count int) []directory {
+	var directories []directory
+	for _, dir := range a.directories {
+		if dir.depth > 0 && (depth == 0 || dir.depth <= depth) {
+			directories = append(directories, dir)
+		}
+	}
+	sort.Slice(directories, func(i, j int) bool {
+		return before(by, a.directoryRank(directories[i]), a.directoryRank(directories[j]))
+	})
+	if count > 0 {
+		if len(directories) > count {
+			return directories[0:count]
+		}
+	}
+	return directories
+}
+
+func (a *analysis) extensionRank(ext extension) rank {
+	return rank{
+		name:    ext.name,
+		path:    ext.name,
+		count:   ext.numFiles,
+		usage:   a.usage(ext.size, ext.diskUsage),
+		modTime: ext.modTime,
+	}
+}
+
+func (a *analysis) fileRank(f file) rank {
+	return rank{
+		name:    f.name,
+		path:    f.path,
+		owner:   f.owner,
+		count:   1,
+		usage:   a.usage(f.size, f.diskUsage),
+		modTime: f.modTime,
+	}
+}
+
+func (a *analysis) directoryRank(dir directory) rank {
+	return rank{
+		name:    dir.name,
+		path:    dir.path,
+		owner:   dir.owner,
+		count:   dir.numFiles,
+		usage:   a.usage(dir.size, dir.diskUsage),
+		modTime: dir.modTime,
+	}
+}
+
 func newAnalysis() analysis {
-	a := analysis{}
+	a := analysis{now: time.Now()}
 	a.extensions = make(map[string]extension)
+	a.directoryIndex = make(map[string]int)
+	a.links = walker.NewLinks()
+	a.owners = owner.NewNames()
+	a.users = make(map[uint32]ownerUsage)
+	a.groups = make(map[uint32]ownerUsage)
 	return a
 }
@@ -0,0 +1,303 @@
+package analyse
+
+import (
+	"encoding/json"
+	"github.com/robinmitra/forest/walker"
+	"io"
+	"time"
+)
+
+// Version of the JSON document. Bump it whenever an existing field changes meaning or is removed.
+const jsonVersion = 4
+
+type jsonFile struct {
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	ApparentSize int64     `json:"apparent_size"`
+	DiskUsage    int64     `json:"disk_usage"`
+	Modified     time.Time `json:"modified"`
+	// Left out when it isn't known.
+	Accessed *time.Time `json:"accessed,omitempty"`
+	Mode     string     `json:"mode"`
+	UID      uint32     `json:"uid"`
+	Owner    string     `json:"owner"`
+}
+
+type jsonAgeBucket struct {
+	Age          string `json:"age"`
+	Files        int    `json:"files"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonAges struct {
+	Modified []jsonAgeBucket `json:"modified"`
+	// Empty when the filesystem doesn't keep track of when files are read.
+	Accessed []jsonAgeBucket `json:"accessed"`
+}
+
+type jsonSizeBucket struct {
+	Size         string `json:"size"`
+	Files        int    `json:"files"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonOwner struct {
+	ID           uint32 `json:"id"`
+	Name         string `json:"name"`
+	Files        int    `json:"files"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonDirectory struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	Files        int    `json:"files"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonRoot struct {
+	Path         string `json:"path"`
+	Files        int    `json:"files"`
+	Directories  int    `json:"directories"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonMount struct {
+	Path    string `json:"path"`
+	Source  string `json:"source"`
+	FSType  string `json:"fs_type"`
+	Skipped bool   `json:"skipped"`
+}
+
+type jsonBrokenLink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+}
+
+type jsonSymlinks struct {
+	Total  int              `json:"total"`
+	Broken []jsonBrokenLink `json:"broken"`
+}
+
+type jsonExtension struct {
+	Name         string `json:"name"`
+	Files        int    `json:"files"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskUsage    int64  `json:"disk_usage"`
+}
+
+type jsonHardLinks struct {
+	Files             int   `json:"files"`
+	SavedApparentSize int64 `json:"saved_apparent_size"`
+	SavedDiskUsage    int64 `json:"saved_disk_usage"`
+}
+
+type jsonSkippedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
+type jsonSkipped struct {
+	Total            int               `json:"total"`
+	PermissionDenied int               `json:"permission_denied"`
+	Vanished         int               `json:"vanished"`
+	IOError          int               `json:"io_error"`
+	Paths            []jsonSkippedPath `json:"paths"`
+}
+
+type jsonSummary struct {
+	Version int    `json:"version"`
+	Root    string `json:"root"`
+	Partial bool   `json:"partial"`
+	// Which of apparent_size and disk_usage drives the totals and sorting, and what the tables are
+	// sorted by, which is one of the --sort keys.
+	Usage        string           `json:"usage"`
+	SortKey      string           `json:"sort_key"`
+	Files        int              `json:"files"`
+	Directories  int              `json:"directories"`
+	ApparentSize int64            `json:"apparent_size"`
+	DiskUsage    int64            `json:"disk_usage"`
+	Roots        []jsonRoot       `json:"roots"`
+	Mounts       []jsonMount      `json:"mounts"`
+	Symlinks     jsonSymlinks     `json:"symlinks"`
+	HardLinks    jsonHardLinks    `json:"hard_links"`
+	Skipped      jsonSkipped      `json:"skipped"`
+	Extensions   []jsonExtension  `json:"extensions"`
+	TopFiles     []jsonFile       `json:"top_files"`
+	TopDirs      []jsonDirectory  `json:"top_directories"`
+	Ages         jsonAges         `json:"ages"`
+	Sizes        []jsonSizeBucket `json:"sizes"`
+	TopOwners    []jsonOwner      `json:"top_owners"`
+	TopGroups    []jsonOwner      `json:"top_groups"`
+	// Only listed when --older-than or --larger-than is given.
+	ArchiveCandidates []jsonFile `json:"archive_candidates"`
+}
+
+func toJSONFile(f file) jsonFile {
+	doc := jsonFile{
+		Name:         f.name,
+		Path:         f.path,
+		ApparentSize: f.size,
+		DiskUsage:    f.diskUsage,
+		Modified:     f.modTime,
+		Mode:         f.mode.String(),
+		UID:          f.uid,
+		Owner:        f.owner,
+	}
+	if !f.accessTime.IsZero() {
+		accessed := f.accessTime
+		doc.Accessed = &accessed
+	}
+	return doc
+}
+
+func toJSONAges(h ageHistogram) []jsonAgeBucket {
+	var buckets []jsonAgeBucket
+	for _, b := range ageBuckets {
+		buckets = append(buckets, jsonAgeBucket{
+			Age:          b.String(),
+			Files:        h[b].numFiles,
+			ApparentSize: h[b].size,
+			DiskUsage:    h[b].diskUsage,
+		})
+	}
+	return buckets
+}
+
+func toJSONSizes(h sizeHistogram) []jsonSizeBucket {
+	var buckets []jsonSizeBucket
+	for _, b := range sizeBuckets {
+		buckets = append(buckets, jsonSizeBucket{
+			Size:         b.String(),
+			Files:        h[b].numFiles,
+			ApparentSize: h[b].size,
+			DiskUsage:    h[b].diskUsage,
+		})
+	}
+	return buckets
+}
+
+func (s summary) toJSONOwners(usages map[uint32]ownerUsage) []jsonOwner {
+	owners := []jsonOwner{}
+	for _, u := range s.analysis.getSortedOwners(usages, s.sortBy, s.top) {
+		owners = append(owners, jsonOwner{
+			ID:           u.id,
+			Name:         u.name,
+			Files:        u.numFiles,
+			ApparentSize: u.size,
+			DiskUsage:    u.diskUsage,
+		})
+	}
+	return owners
+}
+
+func (s summary) toJSON() jsonSummary {
+	doc := jsonSummary{
+		Version:      jsonVersion,
+		Root:         s.root,
+		Partial:      s.partial(),
+		Usage:        "disk_usage",
+		SortKey:      s.sortBy.String(),
+		Files:        s.numFiles,
+		Directories:  s.numDirectories,
+		ApparentSize: s.size,
+		DiskUsage:    s.diskUsage,
+		HardLinks: jsonHardLinks{
+			Files:             s.numHardLinks,
+			SavedApparentSize: s.analysis.hardLinksSize,
+			SavedDiskUsage:    s.analysis.hardLinksDiskUsage,
+		},
+		Skipped: jsonSkipped{
+			Total:            len(s.analysis.skipped.Paths),
+			PermissionDenied: s.analysis.skipped.Count(walker.PermissionDenied),
+			Vanished:         s.analysis.skipped.Count(walker.Vanished),
+			IOError:          s.analysis.skipped.Count(walker.IOError),
+			Paths:            []jsonSkippedPath{},
+		},
+		Roots:      []jsonRoot{},
+		Mounts:     []jsonMount{},
+		Symlinks:   jsonSymlinks{Total: s.analysis.numSymlinks, Broken: []jsonBrokenLink{}},
+		Extensions: []jsonExtension{},
+		TopFiles:   []jsonFile{},
+		TopDirs:    []jsonDirectory{},
+		Ages: jsonAges{
+			Modified: toJSONAges(s.analysis.modified),
+			Accessed: []jsonAgeBucket{},
+		},
+		Sizes:             toJSONSizes(s.analysis.sizes),
+		TopOwners:         s.toJSONOwners(s.analysis.users),
+		TopGroups:         s.toJSONOwners(s.analysis.groups),
+		ArchiveCandidates: []jsonFile{},
+	}
+	if s.analysis.hasAtime {
+		doc.Ages.Accessed = toJSONAges(s.analysis.accessed)
+	}
+	if s.stale.enabled() {
+		for _, f := range s.analysis.getStaleFiles(s.stale) {
+			doc.ArchiveCandidates = append(doc.ArchiveCandidates, toJSONFile(f))
+		}
+	}
+	if s.analysis.apparentSize {
+		doc.Usage = "apparent_size"
+	}
+	for _, root := range s.analysis.roots {
+		doc.Roots = append(doc.Roots, jsonRoot{
+			Path:         root.path,
+			Files:        root.numFiles,
+			Directories:  root.numDirectories,
+			ApparentSize: root.size,
+			DiskUsage:    root.diskUsage,
+		})
+	}
+	for _, l := range s.analysis.brokenLinks {
+		doc.Symlinks.Broken = append(doc.Symlinks.Broken, jsonBrokenLink{Path: l.path, Target: l.target})
+	}
+	for _, m := range s.analysis.mounts {
+		doc.Mounts = append(doc.Mounts, jsonMount{
+			Path:    m.Path,
+			Source:  m.Source,
+			FSType:  m.FSType,
+			Skipped: m.Skipped,
+		})
+	}
+	for _, p := range s.analysis.skipped.Paths {
+		doc.Skipped.Paths = append(doc.Skipped.Paths, jsonSkippedPath{
+			Path:   p.Path,
+			Reason: p.Kind.String(),
+			Error:  p.Err.Error(),
+		})
+	}
+	for _, ext := range s.analysis.getSortedExtensions(s.sortBy, 0) {
+		doc.Extensions = append(doc.Extensions, jsonExtension{
+			Name:         ext.name,
+			Files:        ext.numFiles,
+			ApparentSize: ext.size,
+			DiskUsage:    ext.diskUsage,
+		})
+	}
+	for _, f := range s.analysis.getSortedFiles(s.sortBy, s.top) {
+		doc.TopFiles = append(doc.TopFiles, toJSONFile(f))
+	}
+	for _, dir := range s.analysis.getSortedDirectories(s.sortBy, s.depth, s.top) {
+		doc.TopDirs = append(doc.TopDirs, jsonDirectory{
+			Name:         dir.name,
+			Path:         dir.path,
+			Files:        dir.numFiles,
+			ApparentSize: dir.size,
+			DiskUsage:    dir.diskUsage,
+		})
+	}
+	return doc
+}
+
+func (s summary) printJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s.toJSON())
+}
@@ -0,0 +1,62 @@
+package analyse
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestPrintJSON(t *testing.T) {
+	analysis := newAnalysis()
+	// The mocks don't report any allocated blocks, so rank by apparent size instead.
+	analysis.apparentSize = true
+	var writer bytes.Buffer
+	walkFunc := processFile(&analysis, false, &writer)
+	entries := []struct {
+		path string
+		info fileInfoMock
+	}{
+		{"docs", fileInfoMock{dir: true, basename: "docs"}},
+		{"docs/a.txt", fileInfoMock{basename: "a.txt", size: 100}},
+		{"docs/b.txt", fileInfoMock{basename: "b.txt", size: 200}},
+		{"docs/c.go", fileInfoMock{basename: "c.go", size: 50}},
+	}
+	for _, e := range entries {
+		if err := walkFunc(e.path, e.info, nil); err != nil {
+			t.Fatalf("Unexpected error processing %s: %s", e.path, err)
+		}
+	}
+	s := summary{
+		root:           ".",
+		analysis:       analysis,
+		numFiles:       len(analysis.files),
+		numDirectories: len(analysis.directories),
+		size:           analysis.size,
+		diskUsage:      analysis.diskUsage,
+	}
+
+	var out bytes.Buffer
+	if err := s.printJSON(&out); err != nil {
+		t.Fatalf("Unexpected error printing JSON: %s", err)
+	}
+	var doc jsonSummary
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, found error: %s", err)
+	}
+
+	if doc.Version != jsonVersion {
+		t.Errorf("Expected version %d, found %d", jsonVersion, doc.Version)
+	}
+	if doc.Usage != "apparent_size" || doc.SortKey != "size" {
+		t.Errorf("Expected to be sorted by apparent size, found %s by %s", doc.Usage, doc.SortKey)
+	}
+	if doc.Files != 3 || doc.Directories != 1 || doc.ApparentSize != 350 {
+		t.Errorf("Unexpected totals: %d files, %d directories, %d bytes", doc.Files, doc.Directories, doc.ApparentSize)
+	}
+	if len(doc.Extensions) != 2 || doc.Extensions[0].Name != ".txt" || doc.Extensions[0].ApparentSize != 300 {
+		t.Errorf("Expected .txt to be the largest extension with 300 bytes, found %+v", doc.Extensions)
+	}
+	if len(doc.TopFiles) != 3 || doc.TopFiles[0].Path != "docs/b.txt" {
+		t.Errorf("Expected docs/b.txt to be the largest file, found %+v", doc.TopFiles)
+	}
+}
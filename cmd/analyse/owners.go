@@ -0,0 +1,70 @@
+package analyse
+
+import (
+	"sort"
+)
+
+// The files owned by a user or a group.
+type ownerUsage struct {
+	id        uint32
+	name      string
+	numFiles  int
+	size      int64
+	diskUsage int64
+}
+
+func (u ownerUsage) add(size int64, diskUsage int64) ownerUsage {
+	u.numFiles++
+	u.size += size
+	u.diskUsage += diskUsage
+	return u
+}
+
+// registerOwner counts a file towards the user and the group which own it, if they're known.
+func (a *analysis) registerOwner(uid uint32, gid uint32, size int64, diskUsage int64) {
+	user, ok := a.users[uid]
+	if !ok {
+		user = ownerUsage{id: uid, name: a.owners.User(uid)}
+	}
+	a.users[uid] = user.add(size, diskUsage)
+	group, ok := a.groups[gid]
+	if !ok {
+		group = ownerUsage{id: gid, name: a.owners.Group(gid)}
+	}
+	a.groups[gid] = group.add(size, diskUsage)
+}
+
+// getSortedOwners ranks users or groups. They only have a name, so sorting them by path or owner
+// sorts them by name, and they have no modification time, so sorting them by that sorts them by
+// size.
+func (a *analysis) getSortedOwners(usages map[uint32]ownerUsage, by sortKey, count int) []ownerUsage {
+	switch by {
+	case sortByPath, sortByOwner:
+		by = sortByName
+	case sortByModTime:
+		by = sortBySize
+	}
+	var owners []ownerUsage
+	for _, u := range usages {
+		owners = append(owners, u)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return before(by, a.ownerRank(owners[i]), a.ownerRank(owners[j]))
+	})
+	if count > 0 {
+		if len(owners) > count {
+			return owners[0:count]
+		}
+	}
+	return owners
+}
+
+func (a *analysis) ownerRank(u ownerUsage) rank {
+	return rank{
+		name:  u.name,
+		path:  u.name,
+		owner: u.name,
+		count: u.numFiles,
+		usage: a.usage(u.size, u.diskUsage),
+	}
+}
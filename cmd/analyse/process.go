@@ -3,6 +3,7 @@ package analyse
 import (
 	"fmt"
 	"github.com/gosuri/uilive"
+	"github.com/robinmitra/forest/walker"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
@@ -11,24 +12,55 @@ import (
 	"time"
 )
 
-func process(root string, includeDotFiles bool) summary {
-	analysis := newAnalysis()
+func newProgressWriter() *uilive.Writer {
 	writer := uilive.New()
 	writer.RefreshInterval = time.Nanosecond
-	writer.Start() // Start listening for updates and render.
-	if err := filepath.Walk(root, processFile(&analysis, includeDotFiles, writer)); err != nil {
-		log.Fatal(err)
+	return writer
+}
+
+func process(o *options, writer io.Writer) summary {
+	analysis := newAnalysis()
+	analysis.root = o.root
+	analysis.absolutePaths = o.absolutePaths
+	analysis.apparentSize = o.apparentSize
+	analysis.prefixRoots = len(o.rootPaths()) > 1
+	if o.followSymlinks {
+		analysis.followSymlinks = true
+		analysis.links = walker.NewFollowedLinks()
+	}
+	// Links in a saved scan may point to files on another machine, so they can't be checked.
+	analysis.checkLinks = o.scan == nil
+	walkFunc := processFile(&analysis, o.includeDotFiles, writer)
+	for _, root := range o.rootPaths() {
+		err := analysis.walkRoot(root, func(root string) error {
+			return o.walk(root, walkFunc)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if g := o.guards[root]; g != nil {
+			analysis.mounts = append(analysis.mounts, g.Crossings()...)
+		}
 	}
 	if _, err := fmt.Fprintln(writer, "Done."); err != nil {
 		log.Fatal(err)
 	}
-	writer.Stop()
 	summary := summary{
 		// TODO: Optimise this
-		analysis:       analysis,
-		numFiles:       len(analysis.files),
-		numDirectories: len(analysis.directories),
-		diskUsage:      analysis.diskUsage,
+		root:            o.root,
+		analysis:        analysis,
+		numFiles:        len(analysis.files),
+		numDirectories:  len(analysis.directories),
+		size:            analysis.size,
+		diskUsage:       analysis.diskUsage,
+		numHardLinks:    analysis.numHardLinks,
+		top:             o.top,
+		depth:           o.depth,
+		sortBy:          o.sortBy,
+		sortGiven:       o.sort != "",
+		sections:        o.sections,
+		listBrokenLinks: o.brokenLinks,
+		stale:           o.stale,
 	}
 	return summary
 }
@@ -43,7 +75,10 @@ func isDotFile(path string) bool {
 func processFile(analysis *analysis, includeDotFiles bool, w io.Writer) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			// Carry on past paths that can't be read, but keep track of them.
+			log.Info("Skipping unreadable path: " + path)
+			analysis.skipped.Add(path, err)
+			return nil
 		}
 		if path == "." {
 			return nil
@@ -64,14 +99,56 @@ func processFile(analysis *analysis, includeDotFiles bool, w io.Writer) filepath
 				log.Fatal(err)
 			}
 		}
+		if info.Mode()&os.ModeSymlink != 0 || walker.IsFollowed(info) {
+			analysis.registerLink(path, info)
+			// Links which are passed on as themselves when following links are either broken or
+			// lead to a directory which has been walked already, so they're not files.
+			if analysis.followSymlinks && !walker.IsFollowed(info) {
+				return nil
+			}
+		}
 		if info.IsDir() {
-			analysis.directories = append(analysis.directories, directory{name: filename})
+			_, owner := analysis.ownerOf(info)
+			analysis.registerDirectory(path, directory{name: filename, modTime: info.ModTime(), owner: owner})
 			log.Info("Including file: " + path)
 		} else {
-			analysis.diskUsage += info.Size()
+			size := info.Size()
+			diskUsage := walker.DiskUsage(info)
+			linked, seen := analysis.links.Visit(info)
+			if linked {
+				analysis.numHardLinks++
+				if seen {
+					analysis.hardLinksSize += size
+					analysis.hardLinksDiskUsage += diskUsage
+				}
+			}
+			if seen {
+				// Another link to the same file has been counted already.
+				size, diskUsage = 0, 0
+			}
+			analysis.size += size
+			analysis.diskUsage += diskUsage
+			uid, owner := analysis.ownerOf(info)
 			// TODO may be create a method named registerFile which adds file and extension.
-			analysis.files = append(analysis.files, file{name: filename, size: info.Size()})
-			analysis.registerExtension(filepath.Ext(filename), info.Size())
+			f := file{
+				name:       filename,
+				path:       analysis.displayPath(path),
+				size:       size,
+				diskUsage:  diskUsage,
+				modTime:    info.ModTime(),
+				accessTime: accessTimeOf(info),
+				mode:       info.Mode(),
+				uid:        uid,
+				owner:      owner,
+			}
+			analysis.files = append(analysis.files, f)
+			analysis.registerAge(f)
+			analysis.sizes.add(info.Size(), size, diskUsage)
+			if stat, ok := walker.StatOf(info); ok {
+				analysis.registerOwner(stat.Uid, stat.Gid, size, diskUsage)
+			}
+			analysis.registerExtension(filepath.Ext(filename), size, diskUsage, info.ModTime())
+			analysis.addToDirectories(path, size, diskUsage)
 			log.Info("Including directory: " + path)
 		}
 		return nil
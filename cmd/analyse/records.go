@@ -0,0 +1,121 @@
+package analyse
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/robinmitra/forest/walker"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Columns of the CSV and TSV outputs, with one row for each file and directory.
+var recordColumns = []string{
+	"path",
+	"type",
+	"apparent_size",
+	"allocated_size",
+	"extension",
+	"modified",
+	"uid",
+	"gid",
+	"mode",
+}
+
+func recordType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode.IsRegular():
+		return "file"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "other"
+}
+
+// record returns the row for a file or directory, whose path is as it should be shown.
+func record(path string, info os.FileInfo) []string {
+	var ext, uid, gid string
+	if !info.IsDir() {
+		ext = filepath.Ext(info.Name())
+	}
+	if stat, ok := walker.StatOf(info); ok {
+		uid = strconv.FormatUint(uint64(stat.Uid), 10)
+		gid = strconv.FormatUint(uint64(stat.Gid), 10)
+	}
+	return []string{
+		path,
+		recordType(info.Mode()),
+		strconv.FormatInt(info.Size(), 10),
+		strconv.FormatInt(walker.DiskUsage(info), 10),
+		ext,
+		info.ModTime().Format(time.RFC3339),
+		uid,
+		gid,
+		info.Mode().String(),
+	}
+}
+
+// writeRecords writes a row for every file and directory as soon as it's walked, rather than
+// collecting them first, so that memory use doesn't grow with the size of the tree.
+func (o *options) writeRecords(w io.Writer, skipped *walker.Skipped) error {
+	writer := csv.NewWriter(w)
+	if o.output == outputTSV {
+		writer.Comma = '\t'
+	}
+	if err := writer.Write(recordColumns); err != nil {
+		return err
+	}
+	for _, root := range o.rootPaths() {
+		if err := o.writeRootRecords(root, writer, skipped); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (o *options) writeRootRecords(root string, writer *csv.Writer, skipped *walker.Skipped) error {
+	prefixRoot := len(o.rootPaths()) > 1
+	return o.walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Info("Skipping unreadable path: " + path)
+			skipped.Add(path, err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		if rel != "." && !o.includeDotFiles && isDotFile(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if o.absolutePaths {
+			if abs, err := filepath.Abs(path); err == nil {
+				rel = abs
+			}
+		} else if prefixRoot {
+			// Paths start with the root they're in when there are several roots.
+			rel = filepath.Join(root, rel)
+		}
+		return writer.Write(record(rel, info))
+	})
+}
+
+func (o *options) runRecords() {
+	skipped := walker.Skipped{}
+	if err := o.writeRecords(os.Stdout, &skipped); err != nil {
+		log.Fatal(err)
+	}
+	if len(skipped.Paths) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d paths which couldn't be read.\n", len(skipped.Paths))
+		os.Exit(walker.ExitCodePartial)
+	}
+}
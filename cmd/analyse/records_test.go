@@ -0,0 +1,77 @@
+package analyse
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/robinmitra/forest/walker"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteRecords(t *testing.T) {
+	dir := createTree(t, map[string]int{"docs/notes.md": 300, ".hidden": 0})
+	defer os.RemoveAll(dir)
+
+	for _, output := range []string{outputCSV, outputTSV} {
+		o := options{root: dir, jobs: 1, output: output}
+		var out bytes.Buffer
+		if err := o.writeRecords(&out, &walker.Skipped{}); err != nil {
+			t.Fatalf("Unexpected error writing %s records: %s", output, err)
+		}
+		reader := csv.NewReader(&out)
+		if output == outputTSV {
+			reader.Comma = '\t'
+		}
+		rows, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("Expected valid %s, found error: %s", output, err)
+		}
+		if len(rows) != 4 || !reflect.DeepEqual(rows[0], recordColumns) {
+			t.Fatalf("Expected a header and 3 rows of %s, found %v", output, rows)
+		}
+		paths := []string{rows[1][0], rows[2][0], rows[3][0]}
+		if !reflect.DeepEqual(paths, []string{".", "docs", filepath.Join("docs", "notes.md")}) {
+			t.Errorf("Expected relative paths without hidden files, found %v", paths)
+		}
+		file := rows[3]
+		if file[1] != "file" || file[2] != "300" || file[4] != ".md" || file[8] != "-rw-r--r--" {
+			t.Errorf("Expected the details of notes.md, found %v", file)
+		}
+		if rows[2][1] != "directory" || rows[2][4] != "" {
+			t.Errorf("Expected docs to be a directory without an extension, found %v", rows[2])
+		}
+	}
+}
+
+func TestWriteRecordsWithAbsolutePaths(t *testing.T) {
+	dir := createTree(t, map[string]int{"notes.md": 0})
+	defer os.RemoveAll(dir)
+	// The root is given relative to the working directory, as it usually is on the command line.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Rel(wd, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := options{root: root, jobs: 1, output: outputCSV, absolutePaths: true}
+	var out bytes.Buffer
+	if err := o.writeRecords(&out, &walker.Skipped{}); err != nil {
+		t.Fatalf("Unexpected error writing records: %s", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, found error: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, found %v", rows)
+	}
+	paths := []string{rows[1][0], rows[2][0]}
+	if !reflect.DeepEqual(paths, []string{dir, filepath.Join(dir, "notes.md")}) {
+		t.Errorf("Expected absolute paths, found %v", paths)
+	}
+}
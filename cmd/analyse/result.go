@@ -0,0 +1,224 @@
+package analyse
+
+import (
+	"github.com/robinmitra/forest/filter"
+	"github.com/robinmitra/forest/walker"
+	"io/ioutil"
+	"time"
+)
+
+// Options choose what to analyse, for commands which build on an analysis, such as report.
+type Options struct {
+	// Paths to analyse, or the working directory if there are none.
+	Roots []string
+	// A scan saved by the snapshot command to analyse, rather than the filesystem.
+	From            string
+	IncludeDotFiles bool
+	ApparentSize    bool
+	Jobs            int
+	// How many rows each table has, or all of them if zero.
+	Top int
+	// How far below the root directories are ranked, or all the way down if zero.
+	Depth   int
+	Filters filter.Options
+}
+
+// Totals are the totals of a finished analysis.
+type Totals struct {
+	Files       int
+	Directories int
+	Size        int64
+	DiskUsage   int64
+	// How many files are hard-linked, and how much usage was saved by counting them only once.
+	HardLinks      int
+	HardLinksSaved int64
+}
+
+// Entry is a root, directory, file or file type in a finished analysis. Its usage is either the
+// apparent size or the disk usage, whichever the analysis was asked for.
+type Entry struct {
+	Name string
+	// Path relative to the root being analysed, or starting with the root when there are several.
+	Path string
+	// How far below the root a directory is, where the root itself is 0.
+	Depth   int
+	Files   int
+	Usage   int64
+	ModTime time.Time
+}
+
+// Result is a finished analysis.
+type Result struct {
+	s summary
+}
+
+// Analyse analyses the roots without any progress updates. As with the analyse command, it exits
+// when the options aren't valid.
+func Analyse(opts Options) *Result {
+	o := options{
+		includeDotFiles: opts.IncludeDotFiles,
+		apparentSize:    opts.ApparentSize,
+		jobs:            opts.Jobs,
+		output:          outputText,
+		top:             opts.Top,
+		depth:           opts.Depth,
+		filters:         opts.Filters,
+		from:            opts.From,
+		roots:           opts.Roots,
+	}
+	if len(o.roots) == 0 {
+		o.roots = []string{"."}
+	}
+	o.root = o.roots[0]
+	o.validate()
+	if o.scan != nil {
+		defer o.scan.Close()
+	}
+	return &Result{s: process(&o, ioutil.Discard)}
+}
+
+func (r *Result) Root() string {
+	return r.s.root
+}
+
+// Usage is the total usage of everything analysed.
+func (r *Result) Usage() int64 {
+	return r.s.analysis.usage(r.s.size, r.s.diskUsage)
+}
+
+func (r *Result) Totals() Totals {
+	a := &r.s.analysis
+	return Totals{
+		Files:          r.s.numFiles,
+		Directories:    r.s.numDirectories,
+		Size:           r.s.size,
+		DiskUsage:      r.s.diskUsage,
+		HardLinks:      r.s.numHardLinks,
+		HardLinksSaved: a.usage(a.hardLinksSize, a.hardLinksDiskUsage),
+	}
+}
+
+// Skipped returns the paths which couldn't be read.
+func (r *Result) Skipped() walker.Skipped {
+	return r.s.analysis.skipped
+}
+
+// Partial reports whether some paths couldn't be read, so the results are incomplete.
+func (r *Result) Partial() bool {
+	return r.s.partial()
+}
+
+// UsageLabel describes what the usage of entries is, such as "disk usage".
+func (r *Result) UsageLabel() string {
+	return r.s.usageLabel()
+}
+
+// TopLabel describes how many rows the top entries have, such as "Top 10".
+func (r *Result) TopLabel() string {
+	return r.s.topLabel()
+}
+
+// DepthLabel describes how far below the root directories are ranked, if there's a limit.
+func (r *Result) DepthLabel() string {
+	return r.s.depthLabel()
+}
+
+// Roots returns the totals for each root, when there are several of them.
+func (r *Result) Roots() []Entry {
+	a := &r.s.analysis
+	var roots []Entry
+	if len(a.roots) < 2 {
+		return roots
+	}
+	for _, root := range a.roots {
+		roots = append(roots, Entry{
+			Name:  root.path,
+			Path:  root.path,
+			Files: root.numFiles,
+			Usage: a.usage(root.size, root.diskUsage),
+		})
+	}
+	return roots
+}
+
+// Directories returns every directory below the roots, in the order they were walked.
+func (r *Result) Directories() []Entry {
+	var dirs []Entry
+	for _, dir := range r.s.analysis.directories {
+		if dir.depth > 0 {
+			dirs = append(dirs, r.directoryEntry(dir))
+		}
+	}
+	return dirs
+}
+
+// Files returns every file, in the order they were walked.
+func (r *Result) Files() []Entry {
+	files := make([]Entry, 0, len(r.s.analysis.files))
+	for _, f := range r.s.analysis.files {
+		files = append(files, r.fileEntry(f))
+	}
+	return files
+}
+
+// TopExtensionsByCount returns the file types with the most files.
+func (r *Result) TopExtensionsByCount() []Entry {
+	return r.extensionEntries(r.s.analysis.getSortedExtensions(sortByCount, r.s.top))
+}
+
+// TopExtensionsByUsage returns the file types which take up the most space.
+func (r *Result) TopExtensionsByUsage() []Entry {
+	return r.extensionEntries(r.s.analysis.getSortedExtensions(sortBySize, r.s.top))
+}
+
+// TopFiles returns the files which take up the most space.
+func (r *Result) TopFiles() []Entry {
+	var files []Entry
+	for _, f := range r.s.analysis.getSortedFiles(sortBySize, r.s.top) {
+		files = append(files, r.fileEntry(f))
+	}
+	return files
+}
+
+// TopDirectories returns the directories which take up the most space, up to the depth asked for.
+func (r *Result) TopDirectories() []Entry {
+	var dirs []Entry
+	for _, dir := range r.s.analysis.getSortedDirectories(sortBySize, r.s.depth, r.s.top) {
+		dirs = append(dirs, r.directoryEntry(dir))
+	}
+	return dirs
+}
+
+func (r *Result) fileEntry(f file) Entry {
+	return Entry{
+		Name:    f.name,
+		Path:    f.path,
+		Files:   1,
+		Usage:   r.s.analysis.usage(f.size, f.diskUsage),
+		ModTime: f.modTime,
+	}
+}
+
+func (r *Result) directoryEntry(dir directory) Entry {
+	return Entry{
+		Name:    dir.name,
+		Path:    dir.path,
+		Depth:   dir.depth,
+		Files:   dir.numFiles,
+		Usage:   r.s.analysis.usage(dir.size, dir.diskUsage),
+		ModTime: dir.modTime,
+	}
+}
+
+func (r *Result) extensionEntries(extensions []extension) []Entry {
+	var entries []Entry
+	for _, ext := range extensions {
+		entries = append(entries, Entry{
+			Name:    ext.name,
+			Files:   ext.numFiles,
+			Usage:   r.s.analysis.usage(ext.size, ext.diskUsage),
+			ModTime: ext.modTime,
+		})
+	}
+	return entries
+}
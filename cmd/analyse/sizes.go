@@ -0,0 +1,102 @@
+package analyse
+
+import (
+	"strings"
+)
+
+// sizeBucket is a range of file sizes, growing logarithmically so that small files, which waste
+// the most space for what they hold, get buckets of their own.
+type sizeBucket int
+
+const (
+	sizeEmpty sizeBucket = iota
+	size1K
+	size4K
+	size64K
+	size1M
+	size16M
+	size256M
+	size1G
+	sizeLarger
+)
+
+var sizeBuckets = []sizeBucket{
+	sizeEmpty,
+	size1K,
+	size4K,
+	size64K,
+	size1M,
+	size16M,
+	size256M,
+	size1G,
+	sizeLarger,
+}
+
+// Sizes below which files fall into each bucket, apart from the last one.
+var sizeLimits = []int64{1, 1 << 10, 4 << 10, 64 << 10, 1 << 20, 16 << 20, 256 << 20, 1 << 30}
+
+func (b sizeBucket) String() string {
+	switch b {
+	case sizeEmpty:
+		return "0"
+	case size1K:
+		return "< 1K"
+	case size4K:
+		return "< 4K"
+	case size64K:
+		return "< 64K"
+	case size1M:
+		return "< 1M"
+	case size16M:
+		return "< 16M"
+	case size256M:
+		return "< 256M"
+	case size1G:
+		return "< 1G"
+	}
+	return ">= 1G"
+}
+
+func sizeBucketOf(size int64) sizeBucket {
+	for i, limit := range sizeLimits {
+		if size < limit {
+			return sizeBuckets[i]
+		}
+	}
+	return sizeLarger
+}
+
+// The files in a size bucket.
+type sizeCount struct {
+	numFiles  int
+	size      int64
+	diskUsage int64
+}
+
+// sizeHistogram counts files by their apparent size.
+type sizeHistogram [sizeLarger + 1]sizeCount
+
+// add counts a file of the given apparent size towards its bucket. The size and disk usage it adds
+// to the bucket can be less, such as for hard links which were counted already.
+func (h *sizeHistogram) add(fileSize int64, size int64, diskUsage int64) {
+	c := &h[sizeBucketOf(fileSize)]
+	c.numFiles++
+	c.size += size
+	c.diskUsage += diskUsage
+}
+
+// Width of the bars in the size histogram.
+const histogramWidth = 30
+
+// histogramBar draws a bar which is as long, relative to the width, as value is to max.
+func histogramBar(value int, max int) string {
+	filled := 0
+	if max > 0 {
+		filled = int(float64(value)/float64(max)*histogramWidth + 0.5)
+	}
+	// Buckets which aren't empty always show up.
+	if filled == 0 && value > 0 {
+		filled = 1
+	}
+	return strings.Repeat("#", filled) + strings.Repeat(" ", histogramWidth-filled)
+}
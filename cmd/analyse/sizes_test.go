@@ -0,0 +1,51 @@
+package analyse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSizeBucketOf(t *testing.T) {
+	testCases := []struct {
+		size   int64
+		bucket sizeBucket
+	}{
+		{0, sizeEmpty},
+		{1, size1K},
+		{1023, size1K},
+		{1024, size4K},
+		{4096, size64K},
+		{1<<20 - 1, size1M},
+		{1 << 20, size16M},
+		{256 << 20, size1G},
+		{1 << 30, sizeLarger},
+		{5 << 40, sizeLarger},
+	}
+	for _, tc := range testCases {
+		if b := sizeBucketOf(tc.size); b != tc.bucket {
+			t.Errorf("Expected %d bytes to be in bucket %s, found %s", tc.size, tc.bucket, b)
+		}
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	var h sizeHistogram
+	h.add(100, 100, 4096)
+	h.add(200, 200, 4096)
+	// A hard link to a file which was counted already.
+	h.add(200, 0, 0)
+	h.add(0, 0, 0)
+	if h[size1K] != (sizeCount{numFiles: 3, size: 300, diskUsage: 8192}) {
+		t.Errorf("Expected small files to share a bucket, found %+v", h[size1K])
+	}
+	if h[sizeEmpty].numFiles != 1 {
+		t.Errorf("Expected empty files to have a bucket of their own, found %+v", h)
+	}
+
+	bars := []string{histogramBar(30, 30), histogramBar(15, 30), histogramBar(1, 1000), histogramBar(0, 0)}
+	for i, expected := range []int{histogramWidth, histogramWidth / 2, 1, 0} {
+		if len(bars[i]) != histogramWidth || strings.Count(bars[i], "#") != expected {
+			t.Errorf("Expected a bar of %d, found %q", expected, bars[i])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package analyse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sortKey is what the tables in the summary are sorted by.
+type sortKey int
+
+const (
+	sortBySize sortKey = iota
+	sortByCount
+	sortByName
+	sortByModTime
+	sortByPath
+	sortByOwner
+)
+
+var sortKeys = []sortKey{sortBySize, sortByCount, sortByName, sortByModTime, sortByPath, sortByOwner}
+
+func (k sortKey) String() string {
+	switch k {
+	case sortByCount:
+		return "count"
+	case sortByName:
+		return "name"
+	case sortByModTime:
+		return "mtime"
+	case sortByPath:
+		return "path"
+	case sortByOwner:
+		return "owner"
+	}
+	return "size"
+}
+
+func parseSortKey(s string) (sortKey, error) {
+	for _, k := range sortKeys {
+		if k.String() == s {
+			return k, nil
+		}
+	}
+	var names []string
+	for _, k := range sortKeys {
+		names = append(names, k.String())
+	}
+	return sortBySize, errors.New(fmt.Sprintf(
+		"Unknown sort key \"%s\" (must be one of %s)",
+		s,
+		strings.Join(names, ", "),
+	))
+}
+
+// section is a part of the summary which can be chosen to be printed.
+type section int
+
+const (
+	sectionExtensions section = iota
+	sectionFiles
+	sectionDirectories
+	sectionAges
+	sectionSizes
+	sectionOwners
+)
+
+var allSections = []section{
+	sectionExtensions,
+	sectionFiles,
+	sectionDirectories,
+	sectionAges,
+	sectionSizes,
+	sectionOwners,
+}
+
+func (s section) String() string {
+	switch s {
+	case sectionFiles:
+		return "files"
+	case sectionDirectories:
+		return "directories"
+	case sectionAges:
+		return "ages"
+	case sectionSizes:
+		return "sizes"
+	case sectionOwners:
+		return "owners"
+	}
+	return "extensions"
+}
+
+func parseSections(names []string) ([]section, error) {
+	var sections []section
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, s := range allSections {
+			if s.String() == name {
+				sections = append(sections, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf(
+				"Unknown section \"%s\" (must be one of files, extensions, directories, ages, sizes or owners)",
+				name,
+			))
+		}
+	}
+	return sections, nil
+}
+
+// What files, extensions and directories are ranked by, so that they can all be sorted the same way.
+type rank struct {
+	name    string
+	path    string
+	owner   string
+	count   int
+	usage   int64
+	modTime time.Time
+}
+
+// before reports whether x comes before y when sorted by the given key. Sizes, counts and
+// modification times are sorted largest or newest first, and names, paths and owners
+// alphabetically. Ties are broken by size, and then by name and path, so that the order is always
+// the same.
+func before(by sortKey, x rank, y rank) bool {
+	switch by {
+	case sortByCount:
+		if x.count != y.count {
+			return x.count > y.count
+		}
+	case sortByName:
+		if x.name != y.name {
+			return x.name < y.name
+		}
+	case sortByModTime:
+		if !x.modTime.Equal(y.modTime) {
+			return x.modTime.After(y.modTime)
+		}
+	case sortByPath:
+		if x.path != y.path {
+			return x.path < y.path
+		}
+	case sortByOwner:
+		if x.owner != y.owner {
+			return x.owner < y.owner
+		}
+	}
+	if x.usage != y.usage {
+		return x.usage > y.usage
+	}
+	if x.name != y.name {
+		return x.name < y.name
+	}
+	return x.path < y.path
+}
@@ -0,0 +1,93 @@
+package analyse
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSortKey(t *testing.T) {
+	for _, k := range sortKeys {
+		if parsed, err := parseSortKey(k.String()); err != nil || parsed != k {
+			t.Errorf("Expected %s to be parsed, found %s (%v)", k, parsed, err)
+		}
+	}
+	if _, err := parseSortKey("colour"); err == nil {
+		t.Errorf("Expected unknown sort key to be rejected")
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	sections, err := parseSections([]string{"directories", " files", "owners"})
+	if err != nil {
+		t.Fatalf("Unexpected error parsing sections: %s", err)
+	}
+	if !reflect.DeepEqual(sections, []section{sectionDirectories, sectionFiles, sectionOwners}) {
+		t.Errorf("Expected sections to be parsed in order, found %v", sections)
+	}
+	if _, err := parseSections([]string{"files", "colours"}); err == nil {
+		t.Errorf("Expected unknown section to be rejected")
+	}
+}
+
+func TestSorting(t *testing.T) {
+	analysis := newAnalysis()
+	analysis.apparentSize = true
+	var writer bytes.Buffer
+	walkFunc := processFile(&analysis, false, &writer)
+	day := func(d int) time.Time { return time.Date(2019, time.January, d, 0, 0, 0, 0, time.UTC) }
+	entries := []struct {
+		path string
+		info fileInfoMock
+	}{
+		{"src", fileInfoMock{dir: true, basename: "src", modTime: day(1)}},
+		{"src/a.go", fileInfoMock{basename: "a.go", size: 100, modTime: day(5)}},
+		{"src/b.go", fileInfoMock{basename: "b.go", size: 100, modTime: day(2)}},
+		{"src/c.go", fileInfoMock{basename: "c.go", size: 100, modTime: day(3)}},
+		{"doc", fileInfoMock{dir: true, basename: "doc", modTime: day(9)}},
+		{"doc/d.md", fileInfoMock{basename: "d.md", size: 500, modTime: day(4)}},
+	}
+	for _, e := range entries {
+		if err := walkFunc(e.path, e.info, nil); err != nil {
+			t.Fatalf("Unexpected error processing %s: %s", e.path, err)
+		}
+	}
+
+	testCases := []struct {
+		by          sortKey
+		extensions  []string
+		files       []string
+		directories []string
+	}{
+		{sortBySize, []string{".md", ".go"}, []string{"doc/d.md", "src/a.go", "src/b.go", "src/c.go"}, []string{"doc", "src"}},
+		{sortByCount, []string{".go", ".md"}, []string{"doc/d.md", "src/a.go", "src/b.go", "src/c.go"}, []string{"src", "doc"}},
+		{sortByName, []string{".go", ".md"}, []string{"src/a.go", "src/b.go", "src/c.go", "doc/d.md"}, []string{"doc", "src"}},
+		{sortByPath, []string{".go", ".md"}, []string{"doc/d.md", "src/a.go", "src/b.go", "src/c.go"}, []string{"doc", "src"}},
+		{sortByModTime, []string{".go", ".md"}, []string{"src/a.go", "doc/d.md", "src/c.go", "src/b.go"}, []string{"doc", "src"}},
+	}
+	for _, tc := range testCases {
+		var extensions, files, directories []string
+		for _, ext := range analysis.getSortedExtensions(tc.by, 0) {
+			extensions = append(extensions, ext.name)
+		}
+		for _, f := range analysis.getSortedFiles(tc.by, 0) {
+			files = append(files, f.path)
+		}
+		for _, dir := range analysis.getSortedDirectories(tc.by, 0, 0) {
+			directories = append(directories, dir.path)
+		}
+		if !reflect.DeepEqual(extensions, tc.extensions) {
+			t.Errorf("Expected extensions sorted by %s to be %v, found %v", tc.by, tc.extensions, extensions)
+		}
+		if !reflect.DeepEqual(files, tc.files) {
+			t.Errorf("Expected files sorted by %s to be %v, found %v", tc.by, tc.files, files)
+		}
+		if !reflect.DeepEqual(directories, tc.directories) {
+			t.Errorf("Expected directories sorted by %s to be %v, found %v", tc.by, tc.directories, directories)
+		}
+	}
+	if top := analysis.getSortedFiles(sortBySize, 2); len(top) != 2 {
+		t.Errorf("Expected only the top 2 files, found %d", len(top))
+	}
+}
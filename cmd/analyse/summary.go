@@ -4,43 +4,419 @@ import (
 	"fmt"
 	"github.com/cheynewallace/tabby"
 	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/walker"
 )
 
 type summary struct {
+	root           string
 	analysis       analysis
 	numFiles       int
 	numDirectories int
+	size           int64
 	diskUsage      int64
+	numHardLinks   int
+	// How many rows each table has, or all of them if zero.
+	top int
+	// How far below the root directories are ranked, or all the way down if zero.
+	depth int
+	// What the tables are sorted by, if one was chosen. Otherwise each section has its own default.
+	sortBy    sortKey
+	sortGiven bool
+	sections  []section
+	// Whether to list the symbolic links which are broken.
+	listBrokenLinks bool
+	// Which files to list as candidates for archiving, if any.
+	stale staleFilter
 }
 
-func (s summary) print() {
+func (s summary) print(showErrors bool) {
 	fmt.Println("\nSummary:")
 	fmt.Println("\nFiles:", formatter.HumaniseNumber(int64(s.numFiles)))
 	fmt.Println("Directories:", formatter.HumaniseNumber(int64(s.numDirectories)))
 	fmt.Println("Disk usage:", formatter.HumaniseStorage(s.diskUsage))
+	fmt.Println("Apparent size:", formatter.HumaniseStorage(s.size))
+	if s.numHardLinks > 0 {
+		saved := s.analysis.usage(s.analysis.hardLinksSize, s.analysis.hardLinksDiskUsage)
+		fmt.Printf(
+			"Hard-linked files: %s (%s counted only once)\n",
+			formatter.HumaniseNumber(int64(s.numHardLinks)),
+			formatter.HumaniseStorage(saved),
+		)
+	}
+	if s.analysis.numSymlinks > 0 {
+		s.printSymlinks()
+	}
+	if s.partial() {
+		s.printSkipped(showErrors)
+	}
+	if len(s.analysis.roots) > 1 {
+		s.printRoots()
+	}
+	if len(s.analysis.mounts) > 0 {
+		s.printMounts()
+	}
 	fmt.Println("")
 
+	fmt.Println("Statistics:")
+	for _, sec := range s.sections {
+		for _, by := range s.sortKeys(sec) {
+			switch sec {
+			case sectionExtensions:
+				s.printExtensions(by)
+			case sectionFiles:
+				s.printFiles(by)
+			case sectionDirectories:
+				s.printDirectories(by)
+			case sectionAges:
+				s.printAges()
+			case sectionSizes:
+				s.printSizes()
+			case sectionOwners:
+				s.printOwners(by)
+			}
+		}
+	}
+	if s.stale.enabled() {
+		s.printStaleFiles()
+	}
+}
+
+func (s summary) printSymlinks() {
+	broken := s.analysis.brokenLinks
+	fmt.Printf(
+		"Symbolic links: %s (%s broken)\n",
+		formatter.HumaniseNumber(int64(s.analysis.numSymlinks)),
+		formatter.HumaniseNumber(int64(len(broken))),
+	)
+	if len(broken) == 0 {
+		return
+	}
+	if !s.listBrokenLinks {
+		fmt.Println("Run with --broken-links to list them.")
+		return
+	}
+	t := tabby.New()
+	t.AddHeader("Link", "Target")
+	for _, l := range broken {
+		t.AddLine(l.path, l.target)
+	}
+	t.Print()
+}
+
+func (s summary) printRoots() {
+	fmt.Println("\nRoots:")
 	t := tabby.New()
+	t.AddHeader("Root", "Files", "Directories", "Size")
+	for _, root := range s.analysis.roots {
+		t.AddLine(
+			root.path,
+			formatter.HumaniseNumber(int64(root.numFiles)),
+			formatter.HumaniseNumber(int64(root.numDirectories)),
+			formatter.HumaniseStorage(s.analysis.usage(root.size, root.diskUsage)),
+		)
+	}
+	t.Print()
+}
 
-	fmt.Println("Statistics:")
-	fmt.Println("\nTop 5 file types by occurrence:")
-	t.AddHeader("File type", "Occurrence")
-	for _, ext := range s.analysis.getSortedExtensions("occurrence", 5) {
-		t.AddLine(ext.name, formatter.HumaniseNumber(int64(ext.numFiles)))
+func (s summary) printMounts() {
+	fmt.Println("\nMount points:")
+	t := tabby.New()
+	t.AddHeader("Path", "Type", "Source", "Status")
+	for _, m := range s.analysis.mounts {
+		status := "crossed"
+		if m.Skipped {
+			status = "skipped"
+		}
+		t.AddLine(m.Path, mountLabel(m.FSType), mountLabel(m.Source), status)
+	}
+	t.Print()
+}
+
+// mountLabel shows details of mount points which aren't known, such as on platforms without a
+// mount table, as a dash.
+func mountLabel(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// sortKeys returns what to sort a section by. Unless told otherwise, file types are listed both by
+// occurrence and by size, and everything else by size.
+func (s summary) sortKeys(sec section) []sortKey {
+	if s.sortGiven {
+		return []sortKey{s.sortBy}
+	}
+	if sec == sectionExtensions {
+		return []sortKey{sortByCount, sortBySize}
+	}
+	return []sortKey{sortBySize}
+}
+
+func (s summary) printExtensions(by sortKey) {
+	// File types only have a name, which is their path too, and no owner.
+	if by == sortByPath {
+		by = sortByName
+	} else if by == sortByOwner {
+		by = sortBySize
+	}
+	t := tabby.New()
+	switch by {
+	case sortByCount:
+		fmt.Printf("\n%s file types by occurrence:\n", s.topLabel())
+		t.AddHeader("File type", "Occurrence")
+		for _, ext := range s.analysis.getSortedExtensions(by, s.top) {
+			t.AddLine(ext.name, formatter.HumaniseNumber(int64(ext.numFiles)))
+		}
+	case sortByModTime:
+		fmt.Printf("\n%s file types by last modified:\n", s.topLabel())
+		t.AddHeader("File type", "Last modified")
+		for _, ext := range s.analysis.getSortedExtensions(by, s.top) {
+			t.AddLine(ext.name, formatter.FormatTime(ext.modTime))
+		}
+	default:
+		fmt.Printf("\n%s file types by %s:\n", s.topLabel(), s.sortLabel(by))
+		t.AddHeader("File type", "Size")
+		for _, ext := range s.analysis.getSortedExtensions(by, s.top) {
+			t.AddLine(ext.name, formatter.HumaniseStorage(s.analysis.usage(ext.size, ext.diskUsage)))
+		}
+	}
+	t.Print()
+}
+
+func (s summary) printFiles(by sortKey) {
+	label := s.sortLabel(by)
+	switch by {
+	case sortByCount:
+		// Every file counts once, so sorting them by count sorts them by size.
+		by = sortBySize
+		label = s.usageLabel()
+	case sortBySize:
+		label = s.usageLabel()
+	case sortByModTime:
+		label = "last modified"
+	}
+	fmt.Printf("\n%s files by %s:\n", s.topLabel(), label)
+	t := tabby.New()
+	t.AddHeader("File", "Size", "Last modified", "Mode", "Owner")
+	for _, file := range s.analysis.getSortedFiles(by, s.top) {
+		t.AddLine(
+			file.path,
+			formatter.HumaniseStorage(s.analysis.usage(file.size, file.diskUsage)),
+			formatter.FormatTime(file.modTime),
+			file.mode,
+			ownerLabel(file.owner),
+		)
+	}
+	t.Print()
+}
+
+func (s summary) printDirectories(by sortKey) {
+	t := tabby.New()
+	switch by {
+	case sortByCount:
+		fmt.Printf("\n%s directories%s by number of files:\n", s.topLabel(), s.depthLabel())
+		t.AddHeader("Directory", "Files")
+		for _, dir := range s.analysis.getSortedDirectories(by, s.depth, s.top) {
+			t.AddLine(dir.path, formatter.HumaniseNumber(int64(dir.numFiles)))
+		}
+	case sortByModTime:
+		fmt.Printf("\n%s directories%s by last modified:\n", s.topLabel(), s.depthLabel())
+		t.AddHeader("Directory", "Last modified")
+		for _, dir := range s.analysis.getSortedDirectories(by, s.depth, s.top) {
+			t.AddLine(dir.path, formatter.FormatTime(dir.modTime))
+		}
+	default:
+		fmt.Printf("\n%s directories%s by %s:\n", s.topLabel(), s.depthLabel(), s.sortLabel(by))
+		t.AddHeader("Directory", "Size")
+		for _, dir := range s.analysis.getSortedDirectories(by, s.depth, s.top) {
+			t.AddLine(dir.path, formatter.HumaniseStorage(s.analysis.usage(dir.size, dir.diskUsage)))
+		}
 	}
 	t.Print()
+}
 
-	fmt.Println("\nTop 5 file types by total disk usage:")
-	t.AddHeader("File type", "Size")
-	for _, ext := range s.analysis.getSortedExtensions("size", 5) {
-		t.AddLine(ext.name, formatter.HumaniseStorage(ext.diskUsage))
+// printAges prints how many files were last modified, and last read if that's known, in each age
+// bucket. Ages don't depend on what the other tables are sorted by.
+func (s summary) printAges() {
+	fmt.Println("\nFiles by age:")
+	t := tabby.New()
+	if s.analysis.hasAtime {
+		t.AddHeader("Age", "Modified", "Size", "Accessed", "Size")
+	} else {
+		t.AddHeader("Age", "Modified", "Size")
+	}
+	for _, b := range ageBuckets {
+		modified := s.analysis.modified[b]
+		line := []interface{}{
+			b,
+			formatter.HumaniseNumber(int64(modified.numFiles)),
+			formatter.HumaniseStorage(s.analysis.usage(modified.size, modified.diskUsage)),
+		}
+		if s.analysis.hasAtime {
+			accessed := s.analysis.accessed[b]
+			line = append(
+				line,
+				formatter.HumaniseNumber(int64(accessed.numFiles)),
+				formatter.HumaniseStorage(s.analysis.usage(accessed.size, accessed.diskUsage)),
+			)
+		}
+		t.AddLine(line...)
+	}
+	t.Print()
+}
+
+// printSizes draws how many files there are of each size as a bar chart, along with how much space
+// they take up between them.
+func (s summary) printSizes() {
+	fmt.Println("\nFiles by size:")
+	max := 0
+	for _, c := range s.analysis.sizes {
+		if c.numFiles > max {
+			max = c.numFiles
+		}
+	}
+	t := tabby.New()
+	t.AddHeader("Size", "Files", "", "Total")
+	for _, b := range sizeBuckets {
+		c := s.analysis.sizes[b]
+		t.AddLine(
+			b,
+			formatter.HumaniseNumber(int64(c.numFiles)),
+			"["+histogramBar(c.numFiles, max)+"]",
+			formatter.HumaniseStorage(s.analysis.usage(c.size, c.diskUsage)),
+		)
 	}
 	t.Print()
+}
+
+// printOwners ranks the users, and then the groups, which own the most.
+func (s summary) printOwners(by sortKey) {
+	label := s.sortLabel(by)
+	switch by {
+	case sortByCount:
+		label = "number of files"
+	case sortByPath, sortByOwner:
+		label = "name"
+	case sortByModTime:
+		label = s.sortLabel(sortBySize)
+	}
+	for _, kind := range []struct {
+		title  string
+		header string
+		usages map[uint32]ownerUsage
+	}{
+		{"owners", "Owner", s.analysis.users},
+		{"groups", "Group", s.analysis.groups},
+	} {
+		fmt.Printf("\n%s %s by %s:\n", s.topLabel(), kind.title, label)
+		t := tabby.New()
+		t.AddHeader(kind.header, "Files", "Size")
+		for _, u := range s.analysis.getSortedOwners(kind.usages, by, s.top) {
+			t.AddLine(
+				u.name,
+				formatter.HumaniseNumber(int64(u.numFiles)),
+				formatter.HumaniseStorage(s.analysis.usage(u.size, u.diskUsage)),
+			)
+		}
+		t.Print()
+	}
+}
 
-	fmt.Println("\nTop 5 files by size:")
-	t.AddHeader("File", "Size")
-	for _, file := range s.analysis.getSortedFiles("size", 5) {
-		t.AddLine(file.name, formatter.HumaniseStorage(file.size))
+// printStaleFiles lists every file which is old and large enough to be worth archiving.
+func (s summary) printStaleFiles() {
+	files := s.analysis.getStaleFiles(s.stale)
+	var total int64
+	for _, f := range files {
+		total += s.analysis.usage(f.size, f.diskUsage)
+	}
+	fmt.Printf(
+		"\nArchive candidates: %s (%s)\n",
+		formatter.HumaniseNumber(int64(len(files))),
+		formatter.HumaniseStorage(total),
+	)
+	if len(files) == 0 {
+		return
+	}
+	t := tabby.New()
+	t.AddHeader("File", "Size", "Last modified", "Last accessed")
+	for _, f := range files {
+		accessed := "-"
+		if !f.accessTime.IsZero() {
+			accessed = formatter.FormatTime(f.accessTime)
+		}
+		t.AddLine(
+			f.path,
+			formatter.HumaniseStorage(s.analysis.usage(f.size, f.diskUsage)),
+			formatter.FormatTime(f.modTime),
+			accessed,
+		)
+	}
+	t.Print()
+}
+
+func (s summary) topLabel() string {
+	if s.top > 0 {
+		return fmt.Sprintf("Top %d", s.top)
+	}
+	return "All"
+}
+
+func (s summary) depthLabel() string {
+	switch s.depth {
+	case 0:
+		return ""
+	case 1:
+		return " (1 level deep)"
+	}
+	return fmt.Sprintf(" (up to %d levels deep)", s.depth)
+}
+
+// sortLabel describes the sort keys which show sizes alongside.
+func (s summary) sortLabel(by sortKey) string {
+	switch by {
+	case sortByName, sortByPath, sortByOwner:
+		return by.String()
+	}
+	return "total " + s.usageLabel()
+}
+
+func ownerLabel(owner string) string {
+	if owner == "" {
+		return "-"
+	}
+	return owner
+}
+
+func (s summary) usageLabel() string {
+	if s.analysis.apparentSize {
+		return "apparent size"
+	}
+	return "disk usage"
+}
+
+// partial reports whether some paths couldn't be read, so the results are incomplete.
+func (s summary) partial() bool {
+	return len(s.analysis.skipped.Paths) > 0
+}
+
+func (s summary) printSkipped(showErrors bool) {
+	skipped := s.analysis.skipped
+	fmt.Printf(
+		"Skipped paths: %s (%s permission denied, %s vanished during scan, %s I/O errors)\n",
+		formatter.HumaniseNumber(int64(len(skipped.Paths))),
+		formatter.HumaniseNumber(int64(skipped.Count(walker.PermissionDenied))),
+		formatter.HumaniseNumber(int64(skipped.Count(walker.Vanished))),
+		formatter.HumaniseNumber(int64(skipped.Count(walker.IOError))),
+	)
+	if !showErrors {
+		fmt.Println("Run with --show-errors to list them.")
+		return
+	}
+	t := tabby.New()
+	t.AddHeader("Path", "Reason", "Error")
+	for _, p := range skipped.Paths {
+		t.AddLine(p.Path, p.Kind, p.Err)
 	}
 	t.Print()
 }
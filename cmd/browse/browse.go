@@ -3,52 +3,198 @@ package browse
 import (
 	"errors"
 	"fmt"
+	"github.com/robinmitra/forest/filter"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 )
 
 type options struct {
-	tree bool
-	root string
+	tree            bool
+	list            bool
+	treemap         bool
+	includeDotFiles bool
+	apparentSize    bool
+	showErrors      bool
+	followSymlinks  bool
+	showOwner       bool
+	jobs            int
+	filters         filter.Options
+	// The filter for what's below each root.
+	rootFilters map[string]*filter.Filter
+	from        string
+	scan        *scan.Reader
+	// The first path to browse, and all of them when several were given.
+	root  string
+	roots []string
 }
 
 func (o *options) initialise(cmd *cobra.Command, args []string) {
 	if len(args) != 0 {
 		r, _ := regexp.Compile("/$")
-		o.root = r.ReplaceAllString(args[0], "")
+		for _, arg := range args {
+			o.roots = append(o.roots, r.ReplaceAllString(arg, ""))
+		}
+		o.root = o.roots[0]
 	} else {
 		o.root = "."
+		o.roots = []string{o.root}
 	}
 	if tree, _ := cmd.Flags().GetBool("tree"); tree {
 		o.tree = tree
 	}
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		o.list = list
+	}
+	if treemap, _ := cmd.Flags().GetBool("treemap"); treemap {
+		o.treemap = treemap
+	}
+	if includeDotFiles, _ := cmd.Flags().GetBool("include-hidden-files"); includeDotFiles {
+		o.includeDotFiles = includeDotFiles
+	}
+	if apparentSize, _ := cmd.Flags().GetBool("apparent-size"); apparentSize {
+		o.apparentSize = apparentSize
+	}
+	if showErrors, _ := cmd.Flags().GetBool("show-errors"); showErrors {
+		o.showErrors = showErrors
+	}
+	if followSymlinks, _ := cmd.Flags().GetBool("follow-symlinks"); followSymlinks {
+		o.followSymlinks = followSymlinks
+	}
+	if showOwner, _ := cmd.Flags().GetBool("show-owner"); showOwner {
+		o.showOwner = showOwner
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.jobs = jobs
+	}
+	if exclude, _ := cmd.Flags().GetStringArray("exclude"); len(exclude) > 0 {
+		o.filters.Exclude = exclude
+	}
+	if include, _ := cmd.Flags().GetStringArray("include"); len(include) > 0 {
+		o.filters.Include = include
+	}
+	if excludeFrom, _ := cmd.Flags().GetString("exclude-from"); excludeFrom != "" {
+		o.filters.ExcludeFrom = excludeFrom
+	}
+	if respectGitignore, _ := cmd.Flags().GetBool("respect-gitignore"); respectGitignore {
+		o.filters.RespectGitignore = respectGitignore
+	}
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		o.from = from
+	}
 }
 
 func (o *options) validate() {
-	if err := o.validatePath(os.Stat(o.root)); err != nil {
-		log.Fatal(err)
+	if len(o.roots) > 1 && o.from != "" {
+		log.Fatal("Only one path can be given along with --from")
+	}
+	if o.from != "" {
+		// Work from the saved scan instead, without touching the filesystem.
+		r, err := scan.Open(o.from)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.scan = r
+		o.root = r.Root()
+		o.roots = []string{o.root}
+	} else {
+		for _, root := range o.roots {
+			_, err := os.Stat(root)
+			if err := validateRoot(root, err); err != nil {
+				log.Fatal(err)
+			}
+		}
+		// Roots inside other roots would be shown twice.
+		roots, overlaps := walker.DistinctRoots(o.roots)
+		for _, overlap := range overlaps {
+			fmt.Fprintf(os.Stderr, "Leaving out %s, which is already included in %s\n", overlap.Path, overlap.Within)
+		}
+		o.root = roots[0]
+		o.roots = roots
+	}
+	if o.jobs < 1 {
+		log.Fatal("Number of jobs must be at least 1")
+	}
+	// The filters are built up front, so that bad patterns are reported before walking.
+	o.rootFilters = make(map[string]*filter.Filter)
+	for _, root := range o.roots {
+		f, err := filter.New(root, o.filters)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.rootFilters[root] = f
 	}
 }
 
 func (o *options) validatePath(info os.FileInfo, err error) error {
+	return validateRoot(o.root, err)
+}
+
+func validateRoot(root string, err error) error {
 	if os.IsNotExist(err) {
-		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", o.root))
+		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", root))
 	}
 	return err
 }
 
 func (o *options) run() {
-	if !o.tree {
+	if !o.tree && !o.list && !o.treemap {
 		log.Fatal("Unknown display mode")
 		return
 	}
-	renderTree(buildFileTree(o.root))
+	links := walker.NewLinks()
+	if o.followSymlinks {
+		links = walker.NewFollowedLinks()
+	}
+	tree, skipped := buildFileTrees(o.roots, links, o.walk)
+	switch {
+	case o.treemap:
+		renderTreemap(tree, o.apparentSize)
+	case o.list:
+		renderList(tree, o.apparentSize, o.showOwner)
+	default:
+		renderTree(tree, o.root, o.apparentSize, o.scan != nil, o.showOwner)
+	}
+	if len(skipped.Paths) > 0 {
+		o.reportSkipped(skipped)
+		os.Exit(walker.ExitCodePartial)
+	}
+}
+
+// walk calls fn for every file and directory to browse below a root, whether on the filesystem or
+// in a saved scan.
+func (o *options) walk(root string, fn filepath.WalkFunc) error {
+	opts := walker.Options{
+		Jobs:            o.jobs,
+		IncludeDotFiles: o.includeDotFiles,
+		Skip:            o.rootFilters[root].Skip,
+		FollowSymlinks:  o.followSymlinks,
+	}
+	if o.scan != nil {
+		defer o.scan.Close()
+		return o.scan.Walk(opts, fn)
+	}
+	return walker.Walk(root, opts, fn)
+}
+
+// Once the browser is closed, reports the paths which were left out because they couldn't be read.
+func (o *options) reportSkipped(skipped walker.Skipped) {
+	fmt.Fprintf(os.Stderr, "Skipped %d paths which couldn't be read.\n", len(skipped.Paths))
+	if !o.showErrors {
+		fmt.Fprintln(os.Stderr, "Run with --show-errors to list them.")
+		return
+	}
+	for _, p := range skipped.Paths {
+		fmt.Fprintf(os.Stderr, "%s: %s (%s)\n", p.Path, p.Kind, p.Err)
+	}
 }
 
 var cmd = &cobra.Command{
-	Use:   "browse",
+	Use:   "browse [path...]",
 	Short: "Interactively browse directories and files",
 }
 
@@ -68,6 +214,88 @@ func NewInteractiveCmd() *cobra.Command {
 		true,
 		"browse the file tree",
 	)
+	cmd.Flags().BoolVarP(
+		&o.list,
+		"list",
+		"l",
+		false,
+		"browse one directory at a time, as a list",
+	)
+	cmd.Flags().BoolVar(
+		&o.treemap,
+		"treemap",
+		false,
+		"browse one directory at a time, as a treemap",
+	)
+	cmd.Flags().BoolVarP(
+		&o.includeDotFiles,
+		"include-hidden-files",
+		"a",
+		false,
+		"include hidden files (default is false)",
+	)
+	cmd.Flags().BoolVar(
+		&o.apparentSize,
+		"apparent-size",
+		false,
+		"show apparent sizes rather than disk usage",
+	)
+	cmd.Flags().BoolVar(
+		&o.showErrors,
+		"show-errors",
+		false,
+		"list the paths which couldn't be read, once done browsing",
+	)
+	cmd.Flags().BoolVarP(
+		&o.followSymlinks,
+		"follow-symlinks",
+		"L",
+		false,
+		"follow symbolic links, and show what they point to rather than the links themselves",
+	)
+	cmd.Flags().BoolVar(
+		&o.showOwner,
+		"show-owner",
+		false,
+		"show the user who owns each file and directory, in the tree and the list",
+	)
+	cmd.Flags().StringVar(
+		&o.from,
+		"from",
+		"",
+		"browse a scan saved by the snapshot command, rather than the filesystem",
+	)
+	cmd.Flags().IntVarP(
+		&o.jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories to read concurrently",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Exclude,
+		"exclude",
+		nil,
+		"exclude files and directories matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Include,
+		"include",
+		nil,
+		"only include files matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringVar(
+		&o.filters.ExcludeFrom,
+		"exclude-from",
+		"",
+		"exclude files and directories matching the glob patterns listed in a file",
+	)
+	cmd.Flags().BoolVar(
+		&o.filters.RespectGitignore,
+		"respect-gitignore",
+		false,
+		"exclude files and directories ignored by .gitignore files",
+	)
 
 	return cmd
 }
@@ -2,11 +2,40 @@ package browse
 
 import (
 	"errors"
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+	"github.com/robinmitra/forest/owner"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
 	"github.com/spf13/cobra"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
+type fileInfoMock struct {
+	dir      bool
+	basename string
+	size     int64
+}
+
+func (f fileInfoMock) Name() string       { return f.basename }
+func (f fileInfoMock) ModTime() time.Time { return time.Time{} }
+func (f fileInfoMock) IsDir() bool        { return f.dir }
+func (f fileInfoMock) Size() int64        { return f.size }
+func (f fileInfoMock) Mode() os.FileMode {
+	if f.dir {
+		return 0755 | os.ModeDir
+	}
+	return 0644
+}
+func (f fileInfoMock) Sys() interface{} { return nil }
+
 func TestInvalidPath(t *testing.T) {
 	cmd := cobra.Command{}
 	var args []string
@@ -34,3 +63,214 @@ func TestValidPath(t *testing.T) {
 		t.Errorf("Expected validation to pass when passing valid path.")
 	}
 }
+
+func flattenTree(n *node, prefix string) []string {
+	lines := []string{fmt.Sprintf("%s%s dir=%t size=%d usage=%d", prefix, n.name, n.isDir, n.size, n.diskUsage)}
+	for _, c := range n.children {
+		lines = append(lines, flattenTree(c, prefix+"-")...)
+	}
+	return lines
+}
+
+// createTree creates a temporary directory holding files of the given sizes, by path.
+func createTree(t *testing.T, sizes map[string]int) string {
+	root, err := ioutil.TempDir("", "forest-browse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, size := range sizes {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBuildFileTreeFromScan(t *testing.T) {
+	root := createTree(t, map[string]int{
+		"a/b/file1.txt": 0,
+		"a/file2.txt":   1000,
+		"c/file3.txt":   2000,
+		"file4.txt":     3000,
+	})
+	defer os.RemoveAll(root)
+	opts := walker.Options{Jobs: 2}
+	live, _ := buildFileTree(root, walker.NewLinks(), func(fn filepath.WalkFunc) error {
+		return walker.Walk(root, opts, fn)
+	})
+
+	file, err := ioutil.TempFile("", "forest-browse-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	w, err := scan.Create(file.Name(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := walker.Walk(root, opts, w.Add); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := scan.Open(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	saved, _ := buildFileTree(r.Root(), walker.NewLinks(), func(fn filepath.WalkFunc) error {
+		return r.Walk(opts, fn)
+	})
+
+	if expected, found := flattenTree(live, ""), flattenTree(saved, ""); !reflect.DeepEqual(expected, found) {
+		t.Errorf("Expected tree from scan to match live tree.\nExpected: %v\nFound: %v", expected, found)
+	}
+}
+
+func TestBuildNodesRollsUpSizesToRoot(t *testing.T) {
+	root := node{name: "R", isDir: true}
+	links := walker.NewLinks()
+	buildNodesFromPath(&root, "a", fileInfoMock{dir: true, basename: "a"}, links)
+	buildNodesFromPath(&root, "a/b", fileInfoMock{dir: true, basename: "b"}, links)
+	buildNodesFromPath(&root, "a/b/file1", fileInfoMock{basename: "file1", size: 100}, links)
+	buildNodesFromPath(&root, "a/file2", fileInfoMock{basename: "file2", size: 200}, links)
+
+	if root.size != 300 {
+		t.Fatalf("Expected root node to have size of %d, found %d", 300, root.size)
+	}
+}
+
+func TestBuildFileTreesPutsRootsSideBySide(t *testing.T) {
+	var roots []string
+	for i, p := range []string{"one/file1.txt", "two/sub/file2.txt"} {
+		dir := createTree(t, map[string]int{p: 1000 * (i + 1)})
+		defer os.RemoveAll(dir)
+		roots = append(roots, dir)
+	}
+
+	tree, _ := buildFileTrees(roots, walker.NewLinks(), func(root string, fn filepath.WalkFunc) error {
+		return walker.Walk(root, walker.Options{Jobs: 1}, fn)
+	})
+
+	if tree.name != "2 roots" || tree.size != 3000 || len(tree.children) != 2 {
+		t.Fatalf("Expected a root holding both trees, found %s with %d bytes", tree.name, tree.size)
+	}
+	second := tree.children[1]
+	if second.name != roots[1] || second.size != 2000 || second.parent != tree {
+		t.Errorf("Expected the second root to be named by its path, found %s with %d bytes", second.name, second.size)
+	}
+	sub, _ := second.children[0].getChild("sub")
+	if path := sub.path(""); path != filepath.Join(roots[1], "two", "sub") {
+		t.Errorf("Expected paths below a root to start with it, found %s", path)
+	}
+	_, errs := deleteNodes([]*node{second}, "", func(string) error { return nil })
+	if len(errs) != 1 || len(tree.children) != 2 {
+		t.Errorf("Expected roots being browsed not to be deleted, found errors %v", errs)
+	}
+}
+
+func TestSymlinksShowTheirTarget(t *testing.T) {
+	root := createTree(t, nil)
+	defer os.RemoveAll(root)
+	if err := os.Symlink("elsewhere", filepath.Join(root, "link")); err != nil {
+		t.Skipf("Symbolic links are not supported: %s", err)
+	}
+
+	tree, _ := buildFileTree(root, walker.NewLinks(), func(fn filepath.WalkFunc) error {
+		return walker.Walk(root, walker.Options{}, fn)
+	})
+
+	link, ok := tree.getChild("link")
+	if !ok || !link.isLink || link.link != "elsewhere" {
+		t.Errorf("Expected a link to elsewhere, found %+v", link)
+	}
+}
+
+func TestNodesKnowTheirOwner(t *testing.T) {
+	root := createTree(t, map[string]int{"file": 4})
+	defer os.RemoveAll(root)
+
+	tree, _ := buildFileTree(root, walker.NewLinks(), func(fn filepath.WalkFunc) error {
+		return walker.Walk(root, walker.Options{}, fn)
+	})
+
+	file, _ := tree.getChild("file")
+	if !tree.hasOwner || !file.hasOwner || file.uid != uint32(os.Getuid()) {
+		t.Skipf("Owners are not known on this platform")
+	}
+	names := owner.NewNames()
+	if file.owner(names) != names.User(uint32(os.Getuid())) || tree.owner(names) == "" {
+		t.Errorf("Expected the file and the root to be owned by the current user")
+	}
+	if file.owner(nil) != "" {
+		t.Errorf("Expected no owner to be shown without names, found %s", file.owner(nil))
+	}
+	if m := (&listModel{dir: tree, owners: names}); !strings.Contains(m.row(file), names.User(file.uid)+" ") {
+		t.Errorf("Expected the owner to be shown in the list, found %q", m.row(file))
+	}
+}
+
+// Builds a tree from a map of file paths to sizes, the same way as walking the filesystem would.
+func buildTestTree(name string, files map[string]int64) *node {
+	root := node{name: name, isDir: true}
+	links := walker.NewLinks()
+	for path, size := range files {
+		buildNodesFromPath(&root, path, fileInfoMock{basename: path, size: size}, links)
+	}
+	return &root
+}
+
+func TestSelectingDirectoriesLoadsThemOnce(t *testing.T) {
+	n := tview.NewTreeNode("dir").SetExpanded(false)
+	loads := 0
+	load := func() { loads++ }
+
+	SelectDirectory(n, false, load)
+	if loads != 1 || !n.IsExpanded() {
+		t.Fatalf("Expected the directory to be loaded and expanded, found %d loads", loads)
+	}
+	SelectDirectory(n, true, load)
+	if loads != 1 || n.IsExpanded() {
+		t.Errorf("Expected the directory to be collapsed without loading it again, found %d loads", loads)
+	}
+	SelectDirectory(n, true, load)
+	if !n.IsExpanded() {
+		t.Errorf("Expected the directory to be expanded again")
+	}
+}
+
+func TestCaptureKeys(t *testing.T) {
+	var typed []rune
+	capture := CaptureKeys(func(r rune) bool {
+		typed = append(typed, r)
+		return r == 'm'
+	})
+	key := func(r rune) *tcell.EventKey {
+		return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+
+	if event := capture(key('o')); event == nil || event.Key() != tcell.KeyEnter {
+		t.Errorf("Expected o to select the current node, found %v", event)
+	}
+	if event := capture(key('m')); event != nil {
+		t.Errorf("Expected m to be handled, found %v", event)
+	}
+	if event := capture(key('x')); event == nil || event.Rune() != 'x' {
+		t.Errorf("Expected x to be passed on, found %v", event)
+	}
+	if event := capture(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)); event == nil || event.Key() != tcell.KeyDown {
+		t.Errorf("Expected keys other than runes to be passed on, found %v", event)
+	}
+	if !reflect.DeepEqual(typed, []rune{'m', 'x'}) {
+		t.Errorf("Expected m and x to be handed over, found %v", typed)
+	}
+	if event := CaptureKeys(nil)(key('x')); event == nil {
+		t.Errorf("Expected runes to be passed on when nothing handles them")
+	}
+}
@@ -0,0 +1,83 @@
+package browse
+
+import (
+	"errors"
+	"sort"
+)
+
+// marks are the nodes marked to be deleted.
+type marks map[*node]bool
+
+// toggle marks the node, or unmarks it if it's marked already. It reports false, and leaves the
+// node alone, if it can't be deleted.
+func (m marks) toggle(n *node) bool {
+	if !deletable(n) {
+		return false
+	}
+	if m[n] {
+		delete(m, n)
+	} else {
+		m[n] = true
+	}
+	return true
+}
+
+// has reports whether the node, or one of its ancestors, is marked.
+func (m marks) has(n *node) bool {
+	for ; n != nil; n = n.parent {
+		if m[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// roots returns the marked nodes, leaving out the ones inside marked directories, since deleting
+// the directory deletes them too. They're sorted by path, so that they're always deleted in the same
+// order.
+func (m marks) roots(rootPath string) []*node {
+	var nodes []*node
+	for n := range m {
+		if n.parent == nil || !m.has(n.parent) {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].path(rootPath) < nodes[j].path(rootPath)
+	})
+	return nodes
+}
+
+func totalUsage(nodes []*node, apparentSize bool) int64 {
+	var total int64
+	for _, n := range nodes {
+		total += n.usage(apparentSize)
+	}
+	return total
+}
+
+// deletable reports whether the node can be deleted, which the roots being browsed can't.
+func deletable(n *node) bool {
+	return n.parent != nil && n.rootPath == ""
+}
+
+// deleteNodes deletes the files and directories of the nodes with remove, which either moves them
+// to the trash or deletes them permanently. The nodes which were deleted are taken out of the tree,
+// so that the sizes of their ancestors are up to date without rescanning.
+func deleteNodes(nodes []*node, rootPath string, remove func(path string) error) ([]*node, []error) {
+	var deleted []*node
+	var errs []error
+	for _, n := range nodes {
+		if !deletable(n) {
+			errs = append(errs, errors.New("Can't delete the directory being browsed"))
+			continue
+		}
+		if err := remove(n.path(rootPath)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n.remove()
+		deleted = append(deleted, n)
+	}
+	return deleted, errs
+}
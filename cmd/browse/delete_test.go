@@ -0,0 +1,74 @@
+package browse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMarkedRootsLeaveOutNestedNodes(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{"a/file1": 100, "a/file2": 200, "b/file3": 400})
+	a, _ := root.getChild("a")
+	b, _ := root.getChild("b")
+	file1, _ := a.getChild("file1")
+	file3, _ := b.getChild("file3")
+
+	m := marks{}
+	m.toggle(file1)
+	m.toggle(a)
+	m.toggle(file3)
+	if nodes := m.roots("/R"); !reflect.DeepEqual(nodes, []*node{a, file3}) {
+		t.Errorf("Expected a and b/file3 to be deleted, found %v", nodes)
+	}
+	if total := totalUsage(m.roots("/R"), true); total != 700 {
+		t.Errorf("Expected 700 bytes to be deleted, found %d", total)
+	}
+
+	m.toggle(a)
+	if nodes := m.roots("/R"); !reflect.DeepEqual(nodes, []*node{file1, file3}) {
+		t.Errorf("Expected a/file1 and b/file3 to be deleted once a is unmarked, found %v", nodes)
+	}
+}
+
+func TestDeleteNodesUpdatesTheTree(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{"a/file1": 100, "a/file2": 200, "b/file3": 400})
+	a, _ := root.getChild("a")
+	b, _ := root.getChild("b")
+	file1, _ := a.getChild("file1")
+
+	var removed []string
+	remove := func(path string) error {
+		if path == "/R/b" {
+			return errors.New("permission denied")
+		}
+		removed = append(removed, path)
+		return nil
+	}
+	deleted, errs := deleteNodes([]*node{file1, b, root}, "/R", remove)
+
+	if !reflect.DeepEqual(removed, []string{"/R/a/file1"}) {
+		t.Errorf("Expected only /R/a/file1 to be removed, found %v", removed)
+	}
+	if len(deleted) != 1 || deleted[0] != file1 || len(errs) != 2 {
+		t.Errorf("Expected one node to be deleted and two errors, found %v and %v", deleted, errs)
+	}
+	if a.size != 200 || root.size != 600 {
+		t.Errorf("Expected sizes to be updated without rescanning, found %d and %d", a.size, root.size)
+	}
+}
+
+func TestRootsBeingBrowsedCantBeMarked(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{"a/file1": 100, "b/file2": 200})
+	a, _ := root.getChild("a")
+	b, _ := root.getChild("b")
+	// Each of several roots being browsed is a top-level node, which knows its own path.
+	b.rootPath = "/elsewhere/b"
+
+	m := marks{}
+	if m.toggle(root) || m.toggle(b) || len(m) != 0 {
+		t.Errorf("Expected the roots being browsed not to be marked, found %v", m)
+	}
+	if !m.toggle(a) || !m[a] {
+		t.Errorf("Expected a to be marked")
+	}
+}
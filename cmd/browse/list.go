@@ -0,0 +1,254 @@
+package browse
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/owner"
+	"strings"
+)
+
+// Width of the bar showing how much of its directory an entry takes up.
+const barWidth = 20
+
+// listModel is the state of the list view, which shows one directory at a time, the same as ncdu.
+// It's kept apart from the drawing, so that key handling can be tested without a terminal.
+type listModel struct {
+	root         *node
+	dir          *node
+	apparentSize bool
+	order        order
+	// What looks up the owners of files, when they're shown.
+	owners *owner.Names
+	// Index of the selected entry of the directory, and of the first one shown.
+	selected int
+	offset   int
+}
+
+func newListModel(root *node, apparentSize bool) *listModel {
+	return &listModel{root: root, dir: root, apparentSize: apparentSize, order: defaultOrder}
+}
+
+// entries returns the children of the directory, in the order they're shown.
+func (m *listModel) entries() []*node {
+	return sortNodes(m.dir.children, m.order, m.apparentSize)
+}
+
+func (m *listModel) current() *node {
+	entries := m.entries()
+	if m.selected < 0 || m.selected >= len(entries) {
+		return nil
+	}
+	return entries[m.selected]
+}
+
+// handleKey acts on a key press, and reports whether to carry on, rather than quit.
+func (m *listModel) handleKey(event *tcell.EventKey) bool {
+	switch event.Key() {
+	case tcell.KeyUp:
+		m.move(-1)
+	case tcell.KeyDown:
+		m.move(1)
+	case tcell.KeyHome:
+		m.move(-len(m.dir.children))
+	case tcell.KeyEnd:
+		m.move(len(m.dir.children))
+	case tcell.KeyEnter, tcell.KeyRight:
+		m.enter()
+	case tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyLeft:
+		m.leave()
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'k':
+			m.move(-1)
+		case 'j':
+			m.move(1)
+		case 'l':
+			m.enter()
+		case 'h':
+			m.leave()
+		case 's':
+			m.resort(m.order.next())
+		case 'r':
+			m.resort(m.order.reversed())
+		case 'q':
+			return false
+		}
+	}
+	return true
+}
+
+func (m *listModel) move(delta int) {
+	m.selected += delta
+	if m.selected >= len(m.dir.children) {
+		m.selected = len(m.dir.children) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+}
+
+// enter goes into the selected directory.
+func (m *listModel) enter() {
+	if n := m.current(); n != nil && n.isDir {
+		m.dir = n
+		m.selected = 0
+		m.offset = 0
+	}
+}
+
+// leave goes up to the parent directory, with the directory that was left selected.
+func (m *listModel) leave() {
+	if m.dir.parent == nil {
+		return
+	}
+	left := m.dir
+	m.dir = m.dir.parent
+	m.offset = 0
+	m.selected = 0
+	for i, n := range m.entries() {
+		if n == left {
+			m.selected = i
+		}
+	}
+}
+
+// resort sorts the entries in a different order, keeping the same entry selected.
+func (m *listModel) resort(o order) {
+	current := m.current()
+	m.order = o
+	for i, n := range m.entries() {
+		if n == current {
+			m.selected = i
+		}
+	}
+}
+
+// scroll makes sure the selected entry is shown, when there's room for height entries.
+func (m *listModel) scroll(height int) {
+	if m.selected < m.offset {
+		m.offset = m.selected
+	}
+	if height > 0 && m.selected >= m.offset+height {
+		m.offset = m.selected - height + 1
+	}
+}
+
+// breadcrumb returns the names of the directories from the root down to the current one.
+func (m *listModel) breadcrumb() string {
+	var names []string
+	for n := m.dir; n != nil; n = n.parent {
+		names = append([]string{n.name}, names...)
+	}
+	return strings.Join(names, " / ")
+}
+
+func (m *listModel) header() string {
+	return fmt.Sprintf(
+		"%s  (%s, %d items, sorted by %s)",
+		m.breadcrumb(),
+		formatter.HumaniseStorage(m.dir.usage(m.apparentSize)),
+		len(m.dir.children),
+		m.order,
+	)
+}
+
+// row describes an entry with its size, the share of the directory it takes up, and for
+// directories, how many items are in them.
+func (m *listModel) row(n *node) string {
+	var share float64
+	if total := m.dir.usage(m.apparentSize); total > 0 {
+		share = float64(n.usage(m.apparentSize)) / float64(total)
+	}
+	filled := int(share*barWidth + 0.5)
+	bar := strings.Repeat("#", filled) + strings.Repeat(" ", barWidth-filled)
+	name, items := n.name, ""
+	if n.isDir {
+		name += "/"
+		items = fmt.Sprintf("(%d items)", len(n.children))
+	}
+	if n.link != "" {
+		name += " -> " + n.link
+	}
+	if m.owners != nil {
+		name = fmt.Sprintf("%-10s %s", ownerLabel(n.owner(m.owners)), name)
+	}
+	return fmt.Sprintf(
+		"%11s %5.1f%% [%s] %12s  %s",
+		formatter.HumaniseStorage(n.usage(m.apparentSize)),
+		share*100,
+		bar,
+		items,
+		name,
+	)
+}
+
+// ownerLabel shows owners which aren't known, such as for directories only implied by a list of
+// paths, as a dash.
+func ownerLabel(owner string) string {
+	if owner == "" {
+		return "-"
+	}
+	return owner
+}
+
+// listView draws the list model.
+type listView struct {
+	*tview.Box
+	model *listModel
+	quit  func()
+}
+
+func (v *listView) Draw(screen tcell.Screen) {
+	v.Box.Draw(screen)
+	x, y, width, height := v.GetInnerRect()
+	tview.Print(screen, tview.Escape(v.model.header()), x, y, width, tview.AlignLeft, tcell.ColorYellow)
+	tview.Print(
+		screen,
+		"Enter: open  Backspace: up  s: sort  r: reverse  q: quit",
+		x,
+		y+height-1,
+		width,
+		tview.AlignLeft,
+		tcell.ColorGray,
+	)
+	// The header and the help take up a row each.
+	rows := height - 2
+	v.model.scroll(rows)
+	entries := v.model.entries()
+	for i := 0; i < rows && v.model.offset+i < len(entries); i++ {
+		n := entries[v.model.offset+i]
+		text := tview.Escape(v.model.row(n))
+		color := tcell.ColorWhite
+		if n.isLink {
+			color = tcell.ColorTeal
+		} else if n.isDir {
+			color = tcell.ColorGreen
+		}
+		if v.model.offset+i == v.model.selected {
+			text = "[black:white]" + text + strings.Repeat(" ", width)
+		}
+		tview.Print(screen, text, x, y+1+i, width, tview.AlignLeft, color)
+	}
+}
+
+func (v *listView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return v.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if !v.model.handleKey(event) {
+			v.quit()
+		}
+	})
+}
+
+func renderList(n *node, apparentSize bool, showOwner bool) {
+	app := tview.NewApplication()
+	model := newListModel(n, apparentSize)
+	if showOwner {
+		model.owners = owner.NewNames()
+	}
+	view := &listView{Box: tview.NewBox(), model: model, quit: app.Stop}
+	if err := app.SetRoot(view, true).Run(); err != nil {
+		panic(err)
+	}
+}
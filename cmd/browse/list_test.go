@@ -0,0 +1,99 @@
+package browse
+
+import (
+	"github.com/gdamore/tcell"
+	"strings"
+	"testing"
+)
+
+func key(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModNone)
+}
+
+func letter(r rune) *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+}
+
+func TestListNavigation(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{
+		"small/file1":     100,
+		"big/file2":       3000,
+		"big/inner/file3": 1000,
+		"file4":           500,
+	})
+	m := newListModel(root, true)
+
+	if names := names(m.entries()); strings.Join(names, ",") != "big,file4,small" {
+		t.Fatalf("Expected biggest entries first, found %v", names)
+	}
+	if m.current().name != "big" {
+		t.Fatalf("Expected the first entry to be selected, found %s", m.current().name)
+	}
+
+	m.handleKey(key(tcell.KeyDown))
+	m.handleKey(letter('j'))
+	m.handleKey(key(tcell.KeyDown))
+	if m.current().name != "small" {
+		t.Errorf("Expected moving down to stop at the last entry, found %s", m.current().name)
+	}
+
+	m.handleKey(key(tcell.KeyHome))
+	m.handleKey(key(tcell.KeyEnter))
+	if m.dir.name != "big" || m.breadcrumb() != "R / big" {
+		t.Errorf("Expected to go into big, found %s", m.breadcrumb())
+	}
+	m.handleKey(key(tcell.KeyEnter))
+	if m.dir.name != "big" {
+		t.Errorf("Expected files not to be opened, found %s", m.dir.name)
+	}
+
+	m.handleKey(key(tcell.KeyDown))
+	m.handleKey(key(tcell.KeyEnter))
+	if m.breadcrumb() != "R / big / inner" {
+		t.Errorf("Expected to go into inner, found %s", m.breadcrumb())
+	}
+	m.handleKey(key(tcell.KeyBackspace2))
+	m.handleKey(key(tcell.KeyBackspace2))
+	if m.dir != root || m.current().name != "big" {
+		t.Errorf("Expected to go back up to the root with big selected, found %s", m.breadcrumb())
+	}
+	m.handleKey(key(tcell.KeyBackspace2))
+	if m.dir != root {
+		t.Errorf("Expected to stay at the root")
+	}
+
+	if !m.handleKey(letter('s')) || m.order.key != sortByName || m.current().name != "big" {
+		t.Errorf("Expected to sort by name with the same entry selected")
+	}
+	if m.handleKey(letter('q')) {
+		t.Errorf("Expected q to quit")
+	}
+}
+
+func TestListScrollsToSelection(t *testing.T) {
+	files := make(map[string]int64)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		files[name] = 100
+	}
+	m := newListModel(buildTestTree("R", files), true)
+	m.handleKey(key(tcell.KeyEnd))
+	m.scroll(4)
+	if m.offset != 2 {
+		t.Errorf("Expected to scroll down to the last entry, found offset %d", m.offset)
+	}
+	m.handleKey(key(tcell.KeyHome))
+	m.scroll(4)
+	if m.offset != 0 {
+		t.Errorf("Expected to scroll back up to the first entry, found offset %d", m.offset)
+	}
+}
+
+func TestListRow(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{"dir/file1": 750, "file2": 250})
+	m := newListModel(root, true)
+	dir := m.entries()[0]
+	row := m.row(dir)
+	if !strings.Contains(row, " 75.0% [###############     ]") || !strings.HasSuffix(row, "(1 items)  dir/") {
+		t.Errorf("Unexpected row %q", row)
+	}
+}
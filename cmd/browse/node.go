@@ -1,16 +1,61 @@
 package browse
 
+import (
+	"github.com/robinmitra/forest/owner"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
 type node struct {
-	name     string
-	isDir    bool
-	size     int64
-	children []*node
-	parent   *node
+	name      string
+	isDir     bool
+	size      int64
+	diskUsage int64
+	modTime   time.Time
+	children  []*node
+	parent    *node
+	// Where the node is on the filesystem, when it's one of several roots being browsed together.
+	rootPath string
+	// Whether the node is a symbolic link, followed or not, and where it points.
+	isLink bool
+	link   string
+	// ID of the user who owns the node, if it's known.
+	uid      uint32
+	hasOwner bool
+}
+
+// Node is a file or directory in a tree built by BuildFileTree, for other commands to read.
+type Node struct {
+	n *node
+}
+
+func (n Node) Name() string {
+	return n.n.name
+}
+
+func (n Node) IsDir() bool {
+	return n.n.isDir
+}
+
+// Usage returns either the apparent size or the disk usage of the file or directory, where the
+// size of a directory includes everything inside it.
+func (n Node) Usage(apparentSize bool) int64 {
+	return n.n.usage(apparentSize)
+}
+
+func (n Node) Children() []Node {
+	children := make([]Node, len(n.n.children))
+	for i, c := range n.n.children {
+		children[i] = Node{c}
+	}
+	return children
 }
 
 func (n *node) addChild(c *node) {
 	n.children = append(n.children, c)
 	n.size += c.size
+	n.diskUsage += c.diskUsage
 }
 
 func (n *node) hasChild(name string) bool {
@@ -32,10 +77,70 @@ func (n *node) getChild(name string) (*node, bool) {
 	return nil, false
 }
 
+// find returns the node at a path below this one, with names separated by slashes.
+func (n *node) find(path string) (*node, bool) {
+	found := n
+	for _, name := range strings.Split(path, "/") {
+		c, ok := found.getChild(name)
+		if !ok {
+			return nil, false
+		}
+		found = c
+	}
+	return found, true
+}
+
 func (n *node) recalculateSize() {
-	var s int64
+	var s, u int64
 	for _, c := range n.children {
 		s += c.size
+		u += c.diskUsage
 	}
 	n.size = s
+	n.diskUsage = u
+}
+
+// usage returns either the apparent size or the disk usage of the node.
+func (n *node) usage(apparentSize bool) int64 {
+	if apparentSize {
+		return n.size
+	}
+	return n.diskUsage
+}
+
+// owner returns the name of the user who owns the node, or an empty string when it isn't known or
+// owners aren't shown at all, with nil names.
+func (n *node) owner(names *owner.Names) string {
+	if names == nil || !n.hasOwner {
+		return ""
+	}
+	return names.User(n.uid)
+}
+
+// path returns where the node is on the filesystem, given the path of the root of the tree.
+func (n *node) path(rootPath string) string {
+	if n.rootPath != "" {
+		return n.rootPath
+	}
+	if n.parent == nil {
+		return rootPath
+	}
+	return filepath.Join(n.parent.path(rootPath), n.name)
+}
+
+// remove takes the node out of the tree, and updates the sizes of all of its ancestors.
+func (n *node) remove() {
+	if n.parent == nil {
+		return
+	}
+	for i, c := range n.parent.children {
+		if c == n {
+			n.parent.children = append(n.parent.children[:i], n.parent.children[i+1:]...)
+			break
+		}
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		p.recalculateSize()
+	}
+	n.parent = nil
 }
@@ -70,3 +70,60 @@ func TestCalculateSizeCorrectly(t *testing.T) {
 		t.Fatalf("Expected root node to have size of %d, found %d", 2100, c33.size)
 	}
 }
+
+func TestTracksApparentSizeAndDiskUsage(t *testing.T) {
+	n := node{name: "R", isDir: true}
+	c1 := node{name: "C1", size: 100, diskUsage: 4096}
+	c2 := node{name: "C2", size: 10000, diskUsage: 8192}
+
+	n.addChild(&c1)
+	n.addChild(&c2)
+
+	if n.usage(true) != 10100 {
+		t.Fatalf("Expected node to have apparent size of %d, found %d", 10100, n.usage(true))
+	}
+	if n.usage(false) != 12288 {
+		t.Fatalf("Expected node to have disk usage of %d, found %d", 12288, n.usage(false))
+	}
+
+	c2.diskUsage = 0
+	n.recalculateSize()
+
+	if n.size != 10100 || n.diskUsage != 4096 {
+		t.Fatalf("Expected recalculated node to have sizes of %d and %d, found %d and %d", 10100, 4096, n.size, n.diskUsage)
+	}
+}
+
+func TestPathOfNode(t *testing.T) {
+	root := node{name: "R", isDir: true}
+	dir := node{name: "D", isDir: true, parent: &root}
+	file := node{name: "F", parent: &dir}
+	if p := file.path("/data/R"); p != "/data/R/D/F" {
+		t.Fatalf("Expected path of node to be /data/R/D/F, found %s", p)
+	}
+	if p := root.path("/data/R"); p != "/data/R" {
+		t.Fatalf("Expected path of root to be /data/R, found %s", p)
+	}
+}
+
+func TestRemoveUpdatesAncestors(t *testing.T) {
+	root := node{name: "R", isDir: true}
+	dir := node{name: "D", isDir: true, parent: &root}
+	file1 := node{name: "F1", size: 100, diskUsage: 4096, parent: &dir}
+	file2 := node{name: "F2", size: 200, diskUsage: 4096, parent: &dir}
+	dir.addChild(&file1)
+	dir.addChild(&file2)
+	root.addChild(&dir)
+
+	file1.remove()
+
+	if len(dir.children) != 1 || dir.children[0] != &file2 {
+		t.Fatalf("Expected only F2 to be left in D")
+	}
+	if dir.size != 200 || root.size != 200 || root.diskUsage != 4096 {
+		t.Fatalf("Expected ancestors to be resized, found %d and %d", dir.size, root.size)
+	}
+	if file1.parent != nil {
+		t.Fatalf("Expected removed node to be detached from the tree")
+	}
+}
@@ -0,0 +1,103 @@
+package browse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortKey is what the children of a directory are sorted by.
+type sortKey int
+
+const (
+	sortBySize sortKey = iota
+	sortByName
+	sortByChildren
+	sortByModTime
+)
+
+func (k sortKey) String() string {
+	switch k {
+	case sortByName:
+		return "name"
+	case sortByChildren:
+		return "items"
+	case sortByModTime:
+		return "modified"
+	}
+	return "size"
+}
+
+// order is how the children of a directory are sorted.
+type order struct {
+	key        sortKey
+	descending bool
+}
+
+// The default order puts the biggest files and directories first.
+var defaultOrder = order{key: sortBySize, descending: true}
+
+// next returns the order by the next sort key, in its natural direction: names alphabetically, and
+// everything else biggest or newest first.
+func (o order) next() order {
+	key := (o.key + 1) % (sortByModTime + 1)
+	return order{key: key, descending: key != sortByName}
+}
+
+func (o order) reversed() order {
+	return order{key: o.key, descending: !o.descending}
+}
+
+func (o order) String() string {
+	if o.descending {
+		return fmt.Sprintf("%s (descending)", o.key)
+	}
+	return fmt.Sprintf("%s (ascending)", o.key)
+}
+
+// sortNodes returns the nodes sorted in the given order. Ties are broken by name, so that the order
+// is always the same.
+func sortNodes(nodes []*node, o order, apparentSize bool) []*node {
+	sorted := make([]*node, len(nodes))
+	copy(sorted, nodes)
+	less := func(a *node, b *node) bool {
+		switch o.key {
+		case sortBySize:
+			return a.usage(apparentSize) < b.usage(apparentSize)
+		case sortByChildren:
+			return len(a.children) < len(b.children)
+		case sortByModTime:
+			return a.modTime.Before(b.modTime)
+		}
+		return a.name < b.name
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if o.descending {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+// filterNodes returns the nodes whose names contain the filter, ignoring case.
+func filterNodes(nodes []*node, filter string) []*node {
+	if filter == "" {
+		return nodes
+	}
+	filter = strings.ToLower(filter)
+	var filtered []*node
+	for _, n := range nodes {
+		if strings.Contains(strings.ToLower(n.name), filter) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,68 @@
+package browse
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func names(nodes []*node) []string {
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.name)
+	}
+	return names
+}
+
+func TestSortNodes(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2019, time.January, d, 0, 0, 0, 0, time.UTC) }
+	nodes := []*node{
+		{name: "b", size: 300, modTime: day(1)},
+		{name: "a", size: 100, modTime: day(3), children: []*node{{}, {}}},
+		{name: "c", size: 300, modTime: day(2), children: []*node{{}}},
+	}
+	testCases := []struct {
+		order    order
+		expected []string
+	}{
+		{order{key: sortBySize, descending: true}, []string{"b", "c", "a"}},
+		{order{key: sortBySize}, []string{"a", "b", "c"}},
+		{order{key: sortByName}, []string{"a", "b", "c"}},
+		{order{key: sortByName, descending: true}, []string{"c", "b", "a"}},
+		{order{key: sortByChildren, descending: true}, []string{"a", "c", "b"}},
+		{order{key: sortByModTime, descending: true}, []string{"a", "c", "b"}},
+	}
+	for _, tc := range testCases {
+		if sorted := names(sortNodes(nodes, tc.order, true)); !reflect.DeepEqual(sorted, tc.expected) {
+			t.Errorf("Expected nodes sorted by %s to be %v, found %v", tc.order, tc.expected, sorted)
+		}
+	}
+	if !reflect.DeepEqual(names(nodes), []string{"b", "a", "c"}) {
+		t.Errorf("Expected the nodes passed in to be left alone")
+	}
+}
+
+func TestCyclingOrders(t *testing.T) {
+	o := defaultOrder
+	var keys []sortKey
+	for i := 0; i < 4; i++ {
+		o = o.next()
+		keys = append(keys, o.key)
+	}
+	if !reflect.DeepEqual(keys, []sortKey{sortByName, sortByChildren, sortByModTime, sortBySize}) {
+		t.Errorf("Expected to cycle through every sort key, found %v", keys)
+	}
+	if o != defaultOrder || o.reversed().descending {
+		t.Errorf("Expected reversing to flip the direction of %s", o)
+	}
+}
+
+func TestFilterNodes(t *testing.T) {
+	nodes := []*node{{name: "README.md"}, {name: "readme.txt"}, {name: "main.go"}}
+	if filtered := names(filterNodes(nodes, "ReadMe")); !reflect.DeepEqual(filtered, []string{"README.md", "readme.txt"}) {
+		t.Errorf("Expected names containing the filter to be kept, found %v", filtered)
+	}
+	if filtered := filterNodes(nodes, ""); len(filtered) != 3 {
+		t.Errorf("Expected an empty filter to keep everything, found %d nodes", len(filtered))
+	}
+}
@@ -5,122 +5,549 @@ import (
 	"github.com/gdamore/tcell"
 	"github.com/rivo/tview"
 	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/owner"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/trash"
+	"github.com/robinmitra/forest/walker"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func buildNodesFromPath(n *node, path string, info os.FileInfo) {
+func buildNodesFromPath(n *node, path string, info os.FileInfo, links *walker.Links) {
 	nodeNames := strings.Split(path, "/")
 	currNodeName := nodeNames[0]
 	nestedNodeNames := nodeNames[1:]
 	// Last or trailing node
 	if len(nestedNodeNames) == 0 {
-		newNode := node{name: currNodeName, parent: n}
+		newNode := node{name: currNodeName, modTime: info.ModTime(), parent: n}
+		if stat, ok := walker.StatOf(info); ok {
+			newNode.uid = stat.Uid
+			newNode.hasOwner = true
+		}
 		if info.IsDir() {
 			newNode.isDir = true
 		} else {
 			newNode.isDir = false
-			newNode.size = info.Size()
+			// Only the first link to a hard-linked file gets its size, so that it's counted once.
+			if _, seen := links.Visit(info); !seen {
+				newNode.size = info.Size()
+				newNode.diskUsage = walker.DiskUsage(info)
+			}
 		}
 		n.addChild(&newNode)
 	} else {
 		if existingNode, ok := n.getChild(currNodeName); ok {
-			buildNodesFromPath(existingNode, strings.Join(nestedNodeNames, "/"), info)
-			existingNode.recalculateSize()
+			buildNodesFromPath(existingNode, strings.Join(nestedNodeNames, "/"), info, links)
+			// The child has grown, so this node has too.
+			n.recalculateSize()
 		} else {
 			newNode := node{name: currNodeName, isDir: true, parent: n}
-			buildNodesFromPath(&newNode, strings.Join(nestedNodeNames, "/"), info)
+			buildNodesFromPath(&newNode, strings.Join(nestedNodeNames, "/"), info, links)
 			n.addChild(&newNode)
 		}
 	}
 }
 
-func processFile(node *node, rootPath string) filepath.WalkFunc {
+func processFile(
+	node *node,
+	rootPath string,
+	links *walker.Links,
+	skipped *walker.Skipped,
+) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			// Carry on past paths that can't be read, but keep track of them.
+			skipped.Add(path, err)
+			return nil
 		}
 		name := info.Name()
 		if path == "." || path == rootPath {
 			node.name = name
+			if stat, ok := walker.StatOf(info); ok {
+				node.uid = stat.Uid
+				node.hasOwner = true
+			}
 			return nil
 		}
+		rel := path
 		if rootPath != "." {
-			buildNodesFromPath(node, strings.Replace(path, rootPath+"/", "", 1), info)
-		} else {
-			buildNodesFromPath(node, path, info)
+			rel = strings.Replace(path, rootPath+"/", "", 1)
+		}
+		buildNodesFromPath(node, rel, info, links)
+		if info.Mode()&os.ModeSymlink != 0 || walker.IsFollowed(info) {
+			if n, ok := node.find(rel); ok {
+				n.isLink = true
+				// Links in a saved scan point wherever they did when it was made, rather than to
+				// whatever is at the same path now. Links which can't be read have no target.
+				if target, saved := scan.LinkTarget(info); saved {
+					n.link = target
+				} else {
+					n.link, _ = os.Readlink(path)
+				}
+			}
 		}
 		return nil
 	}
 }
 
-func buildFileTree(root string) *node {
+func buildFileTree(root string, links *walker.Links, walk func(filepath.WalkFunc) error) (*node, walker.Skipped) {
 	rootName := root
 	if root != "." {
 		path := strings.Split(root, "/")
 		rootName = path[len(path)-1]
 	}
 	rootNode := node{name: rootName, isDir: true}
-	if err := filepath.Walk(root, processFile(&rootNode, root)); err != nil {
+	skipped := walker.Skipped{}
+	if err := walk(processFile(&rootNode, root, links, &skipped)); err != nil {
 		log.Fatal(err)
 	}
-	return &rootNode
+	return &rootNode, skipped
+}
+
+// BuildFileTree builds the tree of files and directories below root, which walk calls its function
+// for, along with the paths which couldn't be read. Hard-linked files are only counted once.
+func BuildFileTree(root string, walk func(filepath.WalkFunc) error) (Node, walker.Skipped) {
+	n, skipped := buildFileTree(root, walker.NewLinks(), walk)
+	return Node{n}, skipped
+}
+
+// buildFileTrees builds the tree below each root. When there are several roots, they're put side by
+// side below a root standing for all of them, which isn't a directory of its own. Files are only
+// counted once across all of the roots, however many times links reach them.
+func buildFileTrees(
+	roots []string,
+	links *walker.Links,
+	walk func(string, filepath.WalkFunc) error,
+) (*node, walker.Skipped) {
+	walkRoot := func(root string) func(filepath.WalkFunc) error {
+		return func(fn filepath.WalkFunc) error {
+			return walk(root, fn)
+		}
+	}
+	if len(roots) == 1 {
+		return buildFileTree(roots[0], links, walkRoot(roots[0]))
+	}
+	top := node{name: fmt.Sprintf("%d roots", len(roots)), isDir: true}
+	skipped := walker.Skipped{}
+	for _, root := range roots {
+		n, s := buildFileTree(root, links, walkRoot(root))
+		n.name = root
+		n.rootPath = root
+		n.parent = &top
+		top.addChild(n)
+		skipped.Paths = append(skipped.Paths, s.Paths...)
+	}
+	return &top, skipped
+}
+
+// treeBrowser is the interactive tree of files and directories.
+type treeBrowser struct {
+	root         *node
+	rootPath     string
+	apparentSize bool
+	// Files can't be deleted when browsing a saved scan.
+	readOnly bool
+	marked   marks
+	// The trash that deleted files are moved to, unless there isn't one.
+	trash *trash.Trash
+	// What looks up the owners of files, when they're shown.
+	owners *owner.Names
+	// How the children of each directory are sorted and filtered. Directories which haven't been
+	// sorted yet are sorted the way the last one was.
+	orders    map[*node]order
+	lastOrder order
+	filters   map[*node]string
+	app       *tview.Application
+	pages     *tview.Pages
+	tree      *tview.TreeView
+	footer    *tview.Pages
+	status    *tview.TextView
+	filter    *tview.InputField
+	// The tree nodes showing each node, and whether the children of a directory have been shown
+	// yet, so that they can be updated once files are sorted, filtered or deleted.
+	treeNodes map[*node]*tview.TreeNode
+	loaded    map[*node]bool
 }
 
-func renderTree(n *node) {
-	getNodeText := func(n *node) string {
-		return fmt.Sprintf("%s (%s, %d)", n.name, formatter.HumaniseStorage(n.size), len(n.children))
+func renderTree(n *node, rootPath string, apparentSize bool, readOnly bool, showOwner bool) {
+	b := treeBrowser{
+		root:         n,
+		rootPath:     rootPath,
+		apparentSize: apparentSize,
+		readOnly:     readOnly,
+		marked:       marks{},
+		orders:       make(map[*node]order),
+		lastOrder:    defaultOrder,
+		filters:      make(map[*node]string),
+		treeNodes:    make(map[*node]*tview.TreeNode),
+		loaded:       make(map[*node]bool),
+	}
+	if t, err := trash.Home(); err == nil {
+		b.trash = t
+	}
+	if showOwner {
+		b.owners = owner.NewNames()
 	}
 
-	root := tview.NewTreeNode(getNodeText(n)).SetReference(n).SetColor(tcell.ColorRed)
-	tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+	root := b.newTreeNode(n)
+	b.tree = tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+	b.showChildren(n)
 
-	addChildren := func(n *tview.TreeNode) {
+	b.tree.SetSelectedFunc(func(n *tview.TreeNode) {
 		refNode := n.GetReference().(*node)
-		if len(refNode.children) > 0 {
-			for i, c := range refNode.children {
-				cNode := refNode.children[i]
-				childNode := tview.NewTreeNode(getNodeText(cNode)).SetReference(cNode)
-				if c.isDir {
-					childNode.SetColor(tcell.ColorGreen)
-				}
-				n.AddChild(childNode)
-			}
+		if !refNode.isDir || refNode.parent == nil {
+			// Selecting a file or the root node does nothing.
+			return
 		}
+		SelectDirectory(n, b.loaded[refNode], func() {
+			b.showChildren(refNode)
+		})
+		b.updateStatus()
+	})
+	b.tree.SetChangedFunc(func(*tview.TreeNode) {
+		b.updateStatus()
+	})
+
+	b.tree.SetInputCapture(CaptureKeys(func(r rune) bool {
+		switch r {
+		case 'm':
+			b.toggleMark()
+		case 'd':
+			b.confirmDelete()
+		case 's':
+			b.sortCurrentDir(b.orderOf(b.currentDir()).next())
+		case 'r':
+			b.sortCurrentDir(b.orderOf(b.currentDir()).reversed())
+		case '/':
+			b.openFilter()
+		default:
+			return false
+		}
+		return true
+	}))
+
+	b.status = tview.NewTextView().SetDynamicColors(true)
+	b.filter = tview.NewInputField().SetLabel("Filter: ")
+	b.footer = tview.NewPages().
+		AddPage("filter", b.filter, true, false).
+		AddPage("status", b.status, true, true)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.tree, 0, 1, true).
+		AddItem(b.footer, 1, 0, false)
+	b.pages = tview.NewPages().AddPage("tree", layout, true, true)
+	b.updateStatus()
+
+	b.app = tview.NewApplication().SetRoot(b.pages, true)
+	if err := b.app.Run(); err != nil {
+		panic(err)
 	}
+}
 
-	addChildren(root)
+// SelectDirectory shows the children of a directory with load the first time it's selected, and
+// collapses or expands it after that, so that big trees are only built as far as they're looked at.
+func SelectDirectory(n *tview.TreeNode, loaded bool, load func()) {
+	if !loaded {
+		// Load and show files in this directory.
+		load()
+		n.SetExpanded(true)
+	} else {
+		// Collapse if visible, expand if collapsed.
+		n.SetExpanded(!n.IsExpanded())
+	}
+}
 
-	tree.SetSelectedFunc(func(n *tview.TreeNode) {
-		refNode := n.GetReference()
-		if refNode == nil {
-			// Selecting the root node does nothing.
-			return
+// CaptureKeys returns the input capture of a tree of files and directories, in which o selects the
+// current node just like Enter does. Every other rune typed is handled by keys, if it isn't nil,
+// which reports whether it handled the rune.
+func CaptureKeys(keys func(r rune) bool) func(event *tcell.EventKey) *tcell.EventKey {
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		if event.Rune() == 'o' {
+			return tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
 		}
-		children := n.GetChildren()
-		if len(children) == 0 {
-			// Load and show files in this directory.
-			addChildren(n)
+		if keys != nil && keys(event.Rune()) {
+			return nil
+		}
+		return event
+	}
+}
+
+func (b *treeBrowser) nodeText(n *node) string {
+	details := fmt.Sprintf("%s, %d", formatter.HumaniseStorage(n.usage(b.apparentSize)), len(n.children))
+	if name := n.owner(b.owners); name != "" {
+		details += ", " + name
+	}
+	text := fmt.Sprintf("%s (%s)", n.name, details)
+	if n.link != "" {
+		text = fmt.Sprintf("%s -> %s (%s)", n.name, n.link, details)
+	}
+	if b.marked[n] {
+		text = "* " + text
+	}
+	return text
+}
+
+func (b *treeBrowser) nodeColor(n *node) tcell.Color {
+	switch {
+	case n.parent == nil:
+		return tcell.ColorRed
+	case b.marked.has(n):
+		return tcell.ColorYellow
+	case n.isLink:
+		return tcell.ColorTeal
+	case n.isDir:
+		return tcell.ColorGreen
+	}
+	return tcell.ColorWhite
+}
+
+func (b *treeBrowser) newTreeNode(n *node) *tview.TreeNode {
+	treeNode := tview.NewTreeNode(b.nodeText(n)).SetReference(n).SetColor(b.nodeColor(n))
+	b.treeNodes[n] = treeNode
+	return treeNode
+}
+
+// showChildren shows the children of a directory, sorted and filtered the way they should be. The
+// tree nodes of children which were shown already are kept, so that they stay expanded.
+func (b *treeBrowser) showChildren(dir *node) {
+	var children []*tview.TreeNode
+	for _, c := range filterNodes(sortNodes(dir.children, b.orderOf(dir), b.apparentSize), b.filters[dir]) {
+		if treeNode, ok := b.treeNodes[c]; ok {
+			children = append(children, treeNode)
 		} else {
-			// Collapse if visible, expand if collapsed.
-			n.SetExpanded(!n.IsExpanded())
+			children = append(children, b.newTreeNode(c))
 		}
+	}
+	b.treeNodes[dir].SetChildren(children)
+	b.loaded[dir] = true
+}
+
+func (b *treeBrowser) orderOf(dir *node) order {
+	if o, ok := b.orders[dir]; ok {
+		return o
+	}
+	return b.lastOrder
+}
+
+// currentDir returns the directory whose children are being looked at, which is the current node if
+// it's an expanded directory, and otherwise the directory it's in.
+func (b *treeBrowser) currentDir() *node {
+	n := b.currentNode()
+	if n == nil {
+		return b.root
+	}
+	if n.isDir && b.loaded[n] && b.treeNodes[n].IsExpanded() {
+		return n
+	}
+	if n.parent != nil {
+		return n.parent
+	}
+	return b.root
+}
+
+func (b *treeBrowser) sortCurrentDir(o order) {
+	dir := b.currentDir()
+	b.orders[dir] = o
+	b.lastOrder = o
+	b.showChildren(dir)
+	b.updateStatus()
+}
+
+// openFilter shows the filter of the current directory, which hides its children whose names don't
+// match, as it's typed. Enter keeps the filter, and Escape clears it.
+func (b *treeBrowser) openFilter() {
+	dir := b.currentDir()
+	b.filter.SetText(b.filters[dir])
+	b.filter.SetChangedFunc(func(text string) {
+		b.filters[dir] = text
+		b.showChildren(dir)
 	})
+	b.filter.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			delete(b.filters, dir)
+			b.showChildren(dir)
+		}
+		if !b.isShown(b.currentNode()) {
+			b.tree.SetCurrentNode(b.treeNodes[dir])
+		}
+		b.footer.SwitchToPage("status")
+		b.app.SetFocus(b.tree)
+		b.updateStatus()
+	})
+	b.footer.SwitchToPage("filter")
+	b.app.SetFocus(b.filter)
+}
+
+// isShown reports whether the node is in the tree, and not hidden by the filter of a directory
+// above it.
+func (b *treeBrowser) isShown(n *node) bool {
+	if n == nil || !b.isInside(n, b.root) {
+		return false
+	}
+	for ; n.parent != nil; n = n.parent {
+		if len(filterNodes([]*node{n}, b.filters[n.parent])) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *treeBrowser) updateStatus() {
+	dir := b.currentDir()
+	text := fmt.Sprintf("%s  Sort: %s", dir.name, b.orderOf(dir))
+	if filter := b.filters[dir]; filter != "" {
+		text += fmt.Sprintf("  Filter: %s", filter)
+	}
+	if nodes := b.marked.roots(b.rootPath); len(nodes) > 0 {
+		text += fmt.Sprintf(
+			"  Marked: %d (%s)",
+			len(nodes),
+			formatter.HumaniseStorage(totalUsage(nodes, b.apparentSize)),
+		)
+	}
+	b.status.SetText(tview.Escape(text) + "  [gray]s: sort  r: reverse  /: filter  m: mark  d: delete")
+}
 
-	app := tview.NewApplication().SetRoot(tree, true)
+// refresh updates the text and colour of every tree node shown, after nodes were marked or deleted.
+func (b *treeBrowser) refresh() {
+	for n, treeNode := range b.treeNodes {
+		treeNode.SetText(b.nodeText(n)).SetColor(b.nodeColor(n))
+	}
+	b.updateStatus()
+}
 
-	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyRune && event.Rune() == 'o' {
-			return tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+func (b *treeBrowser) currentNode() *node {
+	if current := b.tree.GetCurrentNode(); current != nil {
+		return current.GetReference().(*node)
+	}
+	return nil
+}
+
+func (b *treeBrowser) toggleMark() {
+	if b.readOnly {
+		b.showMessage("Files can't be deleted when browsing a saved scan.")
+		return
+	}
+	n := b.currentNode()
+	if n == nil {
+		return
+	}
+	if !b.marked.toggle(n) {
+		b.showMessage("Can't delete the directory being browsed.")
+		return
+	}
+	b.refresh()
+}
+
+// confirmDelete asks whether to delete the marked files and directories, or the current one if none
+// are marked.
+func (b *treeBrowser) confirmDelete() {
+	if b.readOnly {
+		b.showMessage("Files can't be deleted when browsing a saved scan.")
+		return
+	}
+	nodes := b.marked.roots(b.rootPath)
+	if len(nodes) == 0 {
+		if n := b.currentNode(); n != nil && n.parent != nil {
+			nodes = []*node{n}
+		}
+	}
+	if len(nodes) == 0 {
+		return
+	}
+	text := fmt.Sprintf(
+		"Delete %d files and directories, using %s?",
+		len(nodes),
+		formatter.HumaniseStorage(totalUsage(nodes, b.apparentSize)),
+	)
+	if len(nodes) == 1 {
+		text = fmt.Sprintf(
+			"Delete %s, using %s?",
+			nodes[0].path(b.rootPath),
+			formatter.HumaniseStorage(nodes[0].usage(b.apparentSize)),
+		)
+	}
+	buttons := []string{"Delete permanently", "Cancel"}
+	if b.trash != nil {
+		buttons = append([]string{"Move to trash"}, buttons...)
+	}
+	modal := tview.NewModal().SetText(text).AddButtons(buttons).SetDoneFunc(func(i int, label string) {
+		b.closeModal()
+		switch label {
+		case "Move to trash":
+			b.delete(nodes, b.trash.Put)
+		case "Delete permanently":
+			b.delete(nodes, os.RemoveAll)
 		}
-		return event
 	})
+	b.pages.AddPage("modal", modal, true, true)
+}
 
-	if err := app.Run(); err != nil {
-		panic(err)
+func (b *treeBrowser) delete(nodes []*node, remove func(path string) error) {
+	deleted, errs := deleteNodes(nodes, b.rootPath, remove)
+	for _, n := range deleted {
+		b.forget(n)
+	}
+	// Show the directories without the deleted nodes.
+	for dir := range b.loaded {
+		b.showChildren(dir)
+	}
+	if !b.isShown(b.currentNode()) {
+		b.tree.SetCurrentNode(b.treeNodes[b.root])
 	}
+	b.refresh()
+	if len(errs) > 0 {
+		var lines []string
+		for _, err := range errs {
+			lines = append(lines, err.Error())
+		}
+		b.showMessage(fmt.Sprintf("Couldn't delete %d paths:\n\n%s", len(errs), strings.Join(lines, "\n")))
+	}
+}
+
+// forget drops everything known about a deleted node, and everything inside it.
+func (b *treeBrowser) forget(n *node) {
+	for m := range b.treeNodes {
+		if b.isInside(m, n) {
+			delete(b.treeNodes, m)
+			delete(b.loaded, m)
+			delete(b.marked, m)
+			delete(b.orders, m)
+			delete(b.filters, m)
+		}
+	}
+	// Marked nodes inside collapsed directories might not have tree nodes.
+	for m := range b.marked {
+		if b.isInside(m, n) {
+			delete(b.marked, m)
+		}
+	}
+}
+
+// isInside reports whether n is dir or anywhere inside it.
+func (b *treeBrowser) isInside(n *node, dir *node) bool {
+	for ; n != nil; n = n.parent {
+		if n == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *treeBrowser) showMessage(text string) {
+	modal := tview.NewModal().SetText(text).AddButtons([]string{"OK"}).SetDoneFunc(func(int, string) {
+		b.closeModal()
+	})
+	b.pages.AddPage("modal", modal, true, true)
+}
+
+func (b *treeBrowser) closeModal() {
+	b.pages.RemovePage("modal")
+	b.app.SetFocus(b.tree)
 }
 
 func debugTree(n *node, spacer string) {
@@ -132,6 +559,7 @@ func debugTree(n *node, spacer string) {
 		fmt.Fprintf(&b, ", parent: %s", n.parent.name)
 	}
 	fmt.Fprintf(&b, ", size: %d", n.size)
+	fmt.Fprintf(&b, ", disk usage: %d", n.diskUsage)
 	fmt.Fprintf(&b, ")")
 	fmt.Println(b.String())
 
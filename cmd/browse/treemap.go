@@ -0,0 +1,324 @@
+package browse
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/robinmitra/forest/formatter"
+	"math"
+	"strings"
+)
+
+// A rectangle in the treemap, laid out in units where a width of 1 is as long as a height of 1.
+type rect struct {
+	x, y, w, h float64
+}
+
+// squarify lays out rectangles with areas proportional to the values, which must be sorted from
+// biggest to smallest, so that together they fill the bounds. Rectangles are kept as close to
+// squares as possible, using the squarified treemap algorithm of Bruls, Huizing and van Wijk.
+func squarify(values []float64, bounds rect) []rect {
+	rects := make([]rect, len(values))
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	if total <= 0 {
+		return rects
+	}
+	scale := bounds.w * bounds.h / total
+	areas := make([]float64, len(values))
+	for i, v := range values {
+		areas[i] = v * scale
+	}
+	for i := 0; i < len(areas); {
+		// Keep adding rectangles to the row along the shorter side, for as long as that makes the
+		// worst aspect ratio in the row better.
+		short := math.Min(bounds.w, bounds.h)
+		j := i + 1
+		for j < len(areas) && worst(areas[i:j+1], short) <= worst(areas[i:j], short) {
+			j++
+		}
+		var sum float64
+		for _, a := range areas[i:j] {
+			sum += a
+		}
+		if bounds.w >= bounds.h {
+			// The row is a column on the left.
+			width := sum / bounds.h
+			y := bounds.y
+			for k := i; k < j; k++ {
+				rects[k] = rect{x: bounds.x, y: y, w: width, h: areas[k] / width}
+				y += areas[k] / width
+			}
+			bounds.x += width
+			bounds.w -= width
+		} else {
+			// The row is along the top.
+			height := sum / bounds.w
+			x := bounds.x
+			for k := i; k < j; k++ {
+				rects[k] = rect{x: x, y: bounds.y, w: areas[k] / height, h: height}
+				x += areas[k] / height
+			}
+			bounds.y += height
+			bounds.h -= height
+		}
+		i = j
+	}
+	return rects
+}
+
+// worst returns the worst aspect ratio of the rectangles of a row along a side of the given length.
+func worst(areas []float64, side float64) float64 {
+	var sum, min, max float64
+	min = math.Inf(1)
+	for _, a := range areas {
+		sum += a
+		min = math.Min(min, a)
+		max = math.Max(max, a)
+	}
+	if sum == 0 || min == 0 {
+		return math.Inf(1)
+	}
+	return math.Max(side*side*max/(sum*sum), sum*sum/(side*side*min))
+}
+
+// A file or directory shown as a rectangle of cells in the terminal.
+type tile struct {
+	node       *node
+	x, y, w, h int
+}
+
+func (t tile) contains(x int, y int) bool {
+	return x >= t.x && x < t.x+t.w && y >= t.y && y < t.y+t.h
+}
+
+// treemapModel is the state of the treemap, which shows the children of one directory at a time. It's
+// kept apart from the drawing, so that key and mouse handling can be tested without a terminal.
+type treemapModel struct {
+	root         *node
+	dir          *node
+	apparentSize bool
+	tiles        []tile
+	selected     int
+	// The size of the last layout, so that the tiles can be laid out again when zooming out.
+	width  int
+	height int
+}
+
+func newTreemapModel(root *node, apparentSize bool) *treemapModel {
+	return &treemapModel{root: root, dir: root, apparentSize: apparentSize}
+}
+
+// layout fills the given number of cells with the children of the directory. Terminal cells are
+// about twice as tall as they're wide, so rows count double, to keep the rectangles looking square.
+func (m *treemapModel) layout(width int, height int) {
+	m.width, m.height = width, height
+	var nodes []*node
+	var values []float64
+	for _, n := range sortNodes(m.dir.children, defaultOrder, m.apparentSize) {
+		if n.usage(m.apparentSize) > 0 {
+			nodes = append(nodes, n)
+			values = append(values, float64(n.usage(m.apparentSize)))
+		}
+	}
+	rects := squarify(values, rect{w: float64(width), h: float64(height) * 2})
+	m.tiles = nil
+	for i, r := range rects {
+		// Edges are rounded, rather than the sizes, so that neighbouring tiles meet without gaps.
+		x0, x1 := int(math.Round(r.x)), int(math.Round(r.x+r.w))
+		y0, y1 := int(math.Round(r.y/2)), int(math.Round((r.y+r.h)/2))
+		if x1 > x0 && y1 > y0 {
+			m.tiles = append(m.tiles, tile{node: nodes[i], x: x0, y: y0, w: x1 - x0, h: y1 - y0})
+		}
+	}
+	if m.selected >= len(m.tiles) {
+		m.selected = len(m.tiles) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+}
+
+func (m *treemapModel) current() *node {
+	if m.selected < len(m.tiles) {
+		return m.tiles[m.selected].node
+	}
+	return nil
+}
+
+// handleKey acts on a key press, and reports whether to carry on, rather than quit.
+func (m *treemapModel) handleKey(event *tcell.EventKey) bool {
+	switch event.Key() {
+	case tcell.KeyRight, tcell.KeyDown, tcell.KeyTab:
+		m.move(1)
+	case tcell.KeyLeft, tcell.KeyUp, tcell.KeyBacktab:
+		m.move(-1)
+	case tcell.KeyEnter:
+		m.zoomIn(m.current())
+	case tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyEscape:
+		m.zoomOut()
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'l', 'j':
+			m.move(1)
+		case 'h', 'k':
+			m.move(-1)
+		case 'q':
+			return false
+		}
+	}
+	return true
+}
+
+// click selects the tile at the given cell, and zooms into it if it's a directory.
+func (m *treemapModel) click(x int, y int) {
+	for i, t := range m.tiles {
+		if t.contains(x, y) {
+			m.selected = i
+			m.zoomIn(t.node)
+			return
+		}
+	}
+}
+
+func (m *treemapModel) move(delta int) {
+	if len(m.tiles) == 0 {
+		return
+	}
+	m.selected = (m.selected + delta + len(m.tiles)) % len(m.tiles)
+}
+
+func (m *treemapModel) zoomIn(n *node) {
+	if n != nil && n.isDir && len(n.children) > 0 {
+		m.dir = n
+		m.selected = 0
+	}
+}
+
+// zoomOut goes up to the parent directory, with the directory that was left selected, unless it's
+// too small to have a tile of its own.
+func (m *treemapModel) zoomOut() {
+	if m.dir.parent == nil {
+		return
+	}
+	left := m.dir
+	m.dir = m.dir.parent
+	m.selected = 0
+	m.layout(m.width, m.height)
+	for i, t := range m.tiles {
+		if t.node == left {
+			m.selected = i
+		}
+	}
+}
+
+func (m *treemapModel) header() string {
+	var names []string
+	for n := m.dir; n != nil; n = n.parent {
+		names = append([]string{n.name}, names...)
+	}
+	header := fmt.Sprintf(
+		"%s (%s)",
+		strings.Join(names, " / "),
+		formatter.HumaniseStorage(m.dir.usage(m.apparentSize)),
+	)
+	if n := m.current(); n != nil {
+		header += fmt.Sprintf("  > %s (%s)", n.name, formatter.HumaniseStorage(n.usage(m.apparentSize)))
+	}
+	return header + "  [Enter/click: zoom in  Backspace: zoom out  q: quit]"
+}
+
+// Background colours of the tiles, which are used in turn, so that neighbours stand apart. Teal is
+// kept for symbolic links, as in the tree.
+var tileColors = []tcell.Color{
+	tcell.ColorNavy,
+	tcell.ColorDarkGreen,
+	tcell.ColorMaroon,
+	tcell.ColorIndigo,
+	tcell.ColorPurple,
+	tcell.ColorOlive,
+	tcell.ColorDarkSlateGray,
+	tcell.ColorSaddleBrown,
+}
+
+func drawText(screen tcell.Screen, x int, y int, width int, text string, style tcell.Style) {
+	for _, r := range text {
+		if width <= 0 {
+			return
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x++
+		width--
+	}
+}
+
+// draw draws the treemap below a header row.
+func (m *treemapModel) draw(screen tcell.Screen) {
+	screen.Clear()
+	width, height := screen.Size()
+	m.layout(width, height-1)
+	drawText(screen, 0, 0, width, m.header(), tcell.StyleDefault.Foreground(tcell.ColorYellow))
+	for i, t := range m.tiles {
+		style := tcell.StyleDefault.Background(tileColors[i%len(tileColors)]).Foreground(tcell.ColorWhite)
+		if t.node.isLink {
+			style = style.Background(tcell.ColorTeal)
+		}
+		if i == m.selected {
+			style = tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlack)
+		}
+		for y := t.y; y < t.y+t.h; y++ {
+			for x := t.x; x < t.x+t.w; x++ {
+				screen.SetContent(x, y+1, ' ', nil, style)
+			}
+		}
+		// Labels are only shown where the whole name fits.
+		name := t.node.name
+		if t.node.isDir {
+			name += "/"
+		}
+		if len([]rune(name)) <= t.w {
+			drawText(screen, t.x, t.y+1, t.w, name, style.Bold(true))
+			size := formatter.HumaniseStorage(t.node.usage(m.apparentSize))
+			if t.h > 1 && len(size) <= t.w {
+				drawText(screen, t.x, t.y+2, t.w, size, style)
+			}
+		}
+	}
+	screen.Show()
+}
+
+func renderTreemap(n *node, apparentSize bool) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		panic(err)
+	}
+	if err := screen.Init(); err != nil {
+		panic(err)
+	}
+	defer screen.Fini()
+	screen.EnableMouse()
+
+	m := newTreemapModel(n, apparentSize)
+	// Mouse events keep coming while the button is held down, but only pressing it is a click.
+	pressed := false
+	for {
+		m.draw(screen)
+		switch event := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			if event.Key() == tcell.KeyCtrlC || !m.handleKey(event) {
+				return
+			}
+		case *tcell.EventMouse:
+			down := event.Buttons()&tcell.Button1 != 0
+			if down && !pressed {
+				x, y := event.Position()
+				// The header takes up the first row.
+				m.click(x, y-1)
+			}
+			pressed = down
+		}
+	}
+}
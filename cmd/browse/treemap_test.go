@@ -0,0 +1,137 @@
+package browse
+
+import (
+	"github.com/gdamore/tcell"
+	"math"
+	"testing"
+)
+
+func TestSquarify(t *testing.T) {
+	values := []float64{6, 6, 4, 3, 2, 2, 1}
+	bounds := rect{w: 6, h: 4}
+	rects := squarify(values, bounds)
+	for i, r := range rects {
+		if area := r.w * r.h; math.Abs(area-values[i]) > 1e-9 {
+			t.Errorf("Expected rectangle %d to have area %f, found %f", i, values[i], area)
+		}
+		if r.x < -1e-9 || r.y < -1e-9 || r.x+r.w > bounds.w+1e-9 || r.y+r.h > bounds.h+1e-9 {
+			t.Errorf("Expected rectangle %d to be inside the bounds, found %+v", i, r)
+		}
+		for j, other := range rects[:i] {
+			overlapX := math.Min(r.x+r.w, other.x+other.w) - math.Max(r.x, other.x)
+			overlapY := math.Min(r.y+r.h, other.y+other.h) - math.Max(r.y, other.y)
+			if overlapX > 1e-9 && overlapY > 1e-9 {
+				t.Errorf("Expected rectangles %d and %d not to overlap, found %+v and %+v", j, i, other, r)
+			}
+		}
+	}
+	// The first two make up the first row, as in the paper.
+	if math.Abs(rects[0].w-3) > 1e-9 || math.Abs(rects[1].x) > 1e-9 || math.Abs(rects[1].y-2) > 1e-9 {
+		t.Errorf("Expected the first row to be a column of width 3, found %+v and %+v", rects[0], rects[1])
+	}
+}
+
+func TestTreemapLayoutFillsEveryCell(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{
+		"a/file1": 5000,
+		"b":       3000,
+		"c":       1500,
+		"d":       400,
+		"e":       100,
+		"empty":   0,
+	})
+	m := newTreemapModel(root, true)
+	width, height := 40, 12
+	m.layout(width, height)
+
+	cells := make(map[[2]int]int)
+	for _, tile := range m.tiles {
+		if tile.node.name == "empty" {
+			t.Errorf("Expected empty files to be left out")
+		}
+		for y := tile.y; y < tile.y+tile.h; y++ {
+			for x := tile.x; x < tile.x+tile.w; x++ {
+				cells[[2]int{x, y}]++
+			}
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if cells[[2]int{x, y}] != 1 {
+				t.Fatalf("Expected cell %d,%d to be covered once, found %d times", x, y, cells[[2]int{x, y}])
+			}
+		}
+	}
+	if m.tiles[0].node.name != "a" || m.tiles[0].w*m.tiles[0].h < width*height/3 {
+		t.Errorf("Expected the biggest tile to come first and take up about half, found %+v", m.tiles[0])
+	}
+}
+
+func TestTreemapZooming(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{"a/file1": 500, "a/file2": 300, "b": 200})
+	m := newTreemapModel(root, true)
+	m.layout(20, 10)
+
+	m.handleKey(tcell.NewEventKey(tcell.KeyRight, 0, tcell.ModNone))
+	if m.current().name != "b" {
+		t.Fatalf("Expected b to be selected, found %s", m.current().name)
+	}
+	m.handleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if m.dir != root {
+		t.Errorf("Expected files not to be zoomed into")
+	}
+
+	a := m.tiles[0]
+	m.click(a.x+a.w-1, a.y+a.h-1)
+	if m.dir.name != "a" {
+		t.Fatalf("Expected clicking a to zoom into it, found %s", m.dir.name)
+	}
+	m.layout(20, 10)
+	if len(m.tiles) != 2 {
+		t.Errorf("Expected the files in a to be shown, found %d tiles", len(m.tiles))
+	}
+
+	m.handleKey(tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone))
+	m.layout(20, 10)
+	if m.dir != root || m.current().name != "a" {
+		t.Errorf("Expected to zoom out with a selected")
+	}
+	if m.handleKey(tcell.NewEventKey(tcell.KeyRune, 'q', tcell.ModNone)) {
+		t.Errorf("Expected q to quit")
+	}
+}
+
+func TestTreemapZoomingOutSelectsTheDirectoryLeft(t *testing.T) {
+	root := buildTestTree("R", map[string]int64{
+		"a/file1":     1000,
+		"b/file2":     900,
+		"c/file3":     800,
+		"empty/file4": 0,
+		"tiny/file5":  1,
+	})
+	m := newTreemapModel(root, true)
+	m.layout(20, 10)
+
+	c := m.tiles[2]
+	m.click(c.x, c.y)
+	m.layout(20, 10)
+	m.handleKey(tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone))
+	m.layout(20, 10)
+	if m.current().name != "c" {
+		t.Errorf("Expected c to be selected after zooming out, found %s", m.current().name)
+	}
+
+	// A directory which was zoomed into before the window shrank can be too small for a tile.
+	tiny, _ := root.find("tiny")
+	m.zoomIn(tiny)
+	m.handleKey(tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone))
+	m.layout(20, 10)
+	for _, tile := range m.tiles {
+		if tile.node == tiny {
+			t.Fatal("Expected tiny to be too small for a tile")
+		}
+	}
+	if m.current().name != "a" {
+		t.Errorf("Expected the first tile to be selected, found %s", m.current().name)
+	}
+}
@@ -0,0 +1,410 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cheynewallace/tabby"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+	"github.com/robinmitra/forest/cmd/browse"
+	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type change int
+
+const (
+	unchanged change = iota
+	added
+	removed
+	grew
+	shrank
+)
+
+func (c change) String() string {
+	switch c {
+	case added:
+		return "added"
+	case removed:
+		return "removed"
+	case grew:
+		return "grew"
+	case shrank:
+		return "shrank"
+	}
+	return "unchanged"
+}
+
+// A file or directory in either or both of the trees being compared. Directory sizes are rolled up
+// from their children, so a file growing deep down shows on all of its ancestors too.
+type diffNode struct {
+	name     string
+	isDir    bool
+	inOld    bool
+	inNew    bool
+	oldSize  int64
+	newSize  int64
+	children []*diffNode
+	parent   *diffNode
+}
+
+func (d *diffNode) delta() int64 {
+	return d.newSize - d.oldSize
+}
+
+func (d *diffNode) change() change {
+	switch {
+	case !d.inOld:
+		return added
+	case !d.inNew:
+		return removed
+	case d.newSize > d.oldSize:
+		return grew
+	case d.newSize < d.oldSize:
+		return shrank
+	}
+	return unchanged
+}
+
+// path returns the path of the node, relative to the roots being compared.
+func (d *diffNode) path() string {
+	if d.parent == nil {
+		return "."
+	}
+	var names []string
+	for n := d; n.parent != nil; n = n.parent {
+		names = append([]string{n.name}, names...)
+	}
+	return strings.Join(names, "/")
+}
+
+// Sorts children so that the ones that grew the most come first, and the ones that shrank the most
+// come last.
+func (d *diffNode) sortChildren() {
+	sort.Slice(d.children, func(i, j int) bool {
+		if d.children[i].delta() == d.children[j].delta() {
+			return d.children[i].name < d.children[j].name
+		}
+		return d.children[i].delta() > d.children[j].delta()
+	})
+}
+
+func diffTrees(before browse.Node, after browse.Node, apparentSize bool) *diffNode {
+	return diffNodes(after.Name(), true, &before, &after, nil, apparentSize)
+}
+
+// Compares a file or directory that's in either or both trees, and then does the same for all of
+// its children.
+func diffNodes(
+	name string,
+	isDir bool,
+	before *browse.Node,
+	after *browse.Node,
+	parent *diffNode,
+	apparentSize bool,
+) *diffNode {
+	d := &diffNode{name: name, isDir: isDir, parent: parent}
+	var names []string
+	oldChildren := make(map[string]*browse.Node)
+	newChildren := make(map[string]*browse.Node)
+	if before != nil {
+		d.inOld = true
+		d.oldSize = before.Usage(apparentSize)
+		children := before.Children()
+		for i := range children {
+			oldChildren[children[i].Name()] = &children[i]
+			names = append(names, children[i].Name())
+		}
+	}
+	if after != nil {
+		d.inNew = true
+		d.newSize = after.Usage(apparentSize)
+		children := after.Children()
+		for i := range children {
+			newChildren[children[i].Name()] = &children[i]
+			if _, ok := oldChildren[children[i].Name()]; !ok {
+				names = append(names, children[i].Name())
+			}
+		}
+	}
+	for _, name := range names {
+		o, n := oldChildren[name], newChildren[name]
+		isDir := (o != nil && o.IsDir()) || (n != nil && n.IsDir())
+		d.children = append(d.children, diffNodes(name, isDir, o, n, d, apparentSize))
+	}
+	d.sortChildren()
+	return d
+}
+
+// changes returns every file and directory which changed, with the biggest changes in either
+// direction first.
+func (d *diffNode) changes() []*diffNode {
+	var changes []*diffNode
+	var collect func(n *diffNode)
+	collect = func(n *diffNode) {
+		if n.parent != nil && n.change() != unchanged {
+			changes = append(changes, n)
+		}
+		for _, c := range n.children {
+			collect(c)
+		}
+	}
+	collect(d)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return abs(changes[i].delta()) > abs(changes[j].delta())
+	})
+	return changes
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatter.HumaniseStorage(-delta)
+	}
+	return "+" + formatter.HumaniseStorage(delta)
+}
+
+func printDiff(d *diffNode, top int) {
+	fmt.Println("\nTotal change:", formatDelta(d.delta()))
+	fmt.Println("Old size:", formatter.HumaniseStorage(d.oldSize))
+	fmt.Println("New size:", formatter.HumaniseStorage(d.newSize))
+
+	changes := d.changes()
+	if top > 0 && len(changes) > top {
+		changes = changes[0:top]
+	}
+	fmt.Printf("\nTop %d changes:\n", len(changes))
+	t := tabby.New()
+	t.AddHeader("Change", "Delta", "Old", "New", "Path")
+	for _, c := range changes {
+		path := c.path()
+		if c.isDir {
+			path += "/"
+		}
+		t.AddLine(
+			c.change(),
+			formatDelta(c.delta()),
+			formatter.HumaniseStorage(c.oldSize),
+			formatter.HumaniseStorage(c.newSize),
+			path,
+		)
+	}
+	t.Print()
+}
+
+func renderDiff(d *diffNode) {
+	getNodeText := func(d *diffNode) string {
+		return fmt.Sprintf(
+			"%s (%s, %s → %s)",
+			d.name,
+			formatDelta(d.delta()),
+			formatter.HumaniseStorage(d.oldSize),
+			formatter.HumaniseStorage(d.newSize),
+		)
+	}
+	getNodeColor := func(d *diffNode) tcell.Color {
+		switch d.change() {
+		case added:
+			return tcell.ColorYellow
+		case removed:
+			return tcell.ColorGray
+		case grew:
+			return tcell.ColorRed
+		case shrank:
+			return tcell.ColorGreen
+		}
+		return tcell.ColorWhite
+	}
+
+	root := tview.NewTreeNode(getNodeText(d)).SetReference(d).SetColor(getNodeColor(d))
+	tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+
+	addChildren := func(n *tview.TreeNode) {
+		refNode := n.GetReference().(*diffNode)
+		for _, c := range refNode.children {
+			n.AddChild(tview.NewTreeNode(getNodeText(c)).SetReference(c).SetColor(getNodeColor(c)))
+		}
+	}
+
+	addChildren(root)
+
+	tree.SetSelectedFunc(func(n *tview.TreeNode) {
+		browse.SelectDirectory(n, len(n.GetChildren()) > 0, func() {
+			addChildren(n)
+		})
+	})
+	tree.SetInputCapture(browse.CaptureKeys(nil))
+
+	if err := tview.NewApplication().SetRoot(tree, true).Run(); err != nil {
+		panic(err)
+	}
+}
+
+type options struct {
+	includeDotFiles bool
+	apparentSize    bool
+	browse          bool
+	showErrors      bool
+	top             int
+	jobs            int
+	old             string
+	new             string
+}
+
+func (o *options) initialise(cmd *cobra.Command, args []string) {
+	o.old = args[0]
+	o.new = args[1]
+	if includeDotFiles, _ := cmd.Flags().GetBool("include-hidden-files"); includeDotFiles {
+		o.includeDotFiles = includeDotFiles
+	}
+	if apparentSize, _ := cmd.Flags().GetBool("apparent-size"); apparentSize {
+		o.apparentSize = apparentSize
+	}
+	if browse, _ := cmd.Flags().GetBool("browse"); browse {
+		o.browse = browse
+	}
+	if showErrors, _ := cmd.Flags().GetBool("show-errors"); showErrors {
+		o.showErrors = showErrors
+	}
+	if top, _ := cmd.Flags().GetInt("top"); top >= 0 {
+		o.top = top
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.jobs = jobs
+	}
+}
+
+func (o *options) validate() {
+	for _, path := range []string{o.old, o.new} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.Fatal(errors.New(fmt.Sprintf("Scan or directory \"%s\" does not exist", path)))
+		}
+	}
+	if o.jobs < 1 {
+		log.Fatal("Number of jobs must be at least 1")
+	}
+	if o.top < 0 {
+		log.Fatal("Number of changes to list can't be negative")
+	}
+}
+
+// Builds the tree of either a saved scan or a directory, along with the paths which couldn't be
+// read.
+func (o *options) buildTree(path string) (browse.Node, walker.Skipped) {
+	opts := walker.Options{Jobs: o.jobs, IncludeDotFiles: o.includeDotFiles}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		root := strings.TrimSuffix(path, "/")
+		return browse.BuildFileTree(root, func(fn filepath.WalkFunc) error {
+			return walker.Walk(root, opts, fn)
+		})
+	}
+	r, err := scan.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	return browse.BuildFileTree(r.Root(), func(fn filepath.WalkFunc) error {
+		return r.Walk(opts, fn)
+	})
+}
+
+func (o *options) run() {
+	before, skipped := o.buildTree(o.old)
+	after, newSkipped := o.buildTree(o.new)
+	// Paths which couldn't be read would otherwise look as though they were added or removed.
+	skipped.Paths = append(skipped.Paths, newSkipped.Paths...)
+	d := diffTrees(before, after, o.apparentSize)
+	if o.browse {
+		renderDiff(d)
+	} else {
+		printDiff(d, o.top)
+	}
+	if len(skipped.Paths) > 0 {
+		o.reportSkipped(os.Stderr, skipped)
+		os.Exit(walker.ExitCodePartial)
+	}
+}
+
+// Reports the paths which were left out of either side because they couldn't be read.
+func (o *options) reportSkipped(w io.Writer, skipped walker.Skipped) {
+	fmt.Fprintf(w, "Skipped %d paths which couldn't be read, so the changes are partial.\n", len(skipped.Paths))
+	if !o.showErrors {
+		fmt.Fprintln(w, "Run with --show-errors to list them.")
+		return
+	}
+	for _, p := range skipped.Paths {
+		fmt.Fprintf(w, "%s: %s (%s)\n", p.Path, p.Kind, p.Err)
+	}
+}
+
+var cmd = &cobra.Command{
+	Use:   "diff old new",
+	Short: "Show what changed between two saved scans or directories",
+	Args:  cobra.ExactArgs(2),
+}
+
+func NewDiffCmd() *cobra.Command {
+	o := options{}
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		o.initialise(cmd, args)
+		o.validate()
+		o.run()
+	}
+
+	cmd.Flags().BoolVarP(
+		&o.includeDotFiles,
+		"include-hidden-files",
+		"a",
+		false,
+		"include hidden files (default is false)",
+	)
+	cmd.Flags().BoolVar(
+		&o.apparentSize,
+		"apparent-size",
+		false,
+		"compare apparent sizes rather than disk usage",
+	)
+	cmd.Flags().BoolVarP(
+		&o.browse,
+		"browse",
+		"b",
+		false,
+		"browse the changes as a tree, rather than listing them",
+	)
+	cmd.Flags().BoolVar(
+		&o.showErrors,
+		"show-errors",
+		false,
+		"list the paths which couldn't be read, on either side",
+	)
+	cmd.Flags().IntVar(
+		&o.top,
+		"top",
+		20,
+		"number of changes to list, or 0 for all of them",
+	)
+	cmd.Flags().IntVarP(
+		&o.jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories to read concurrently",
+	)
+
+	return cmd
+}
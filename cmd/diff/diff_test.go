@@ -0,0 +1,187 @@
+package diff
+
+import (
+	"bytes"
+	"github.com/robinmitra/forest/cmd/browse"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fileInfoMock struct {
+	basename string
+	size     int64
+}
+
+func (f fileInfoMock) Name() string       { return f.basename }
+func (f fileInfoMock) ModTime() time.Time { return time.Time{} }
+func (f fileInfoMock) IsDir() bool        { return false }
+func (f fileInfoMock) Size() int64        { return f.size }
+func (f fileInfoMock) Mode() os.FileMode  { return 0644 }
+func (f fileInfoMock) Sys() interface{}   { return nil }
+
+// Builds a tree from a map of file paths to sizes, the same way as walking the filesystem would.
+func buildTestTree(name string, files map[string]int64) browse.Node {
+	tree, _ := browse.BuildFileTree(name, func(fn filepath.WalkFunc) error {
+		for path, size := range files {
+			if err := fn(name+"/"+path, fileInfoMock{basename: filepath.Base(path), size: size}, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return tree
+}
+
+func TestDiffTrees(t *testing.T) {
+	before := buildTestTree("R", map[string]int64{
+		"logs/app.log":     1000,
+		"logs/old.log":     500,
+		"data/a/big.bin":   10000,
+		"data/a/small.bin": 100,
+		"README":           50,
+	})
+	after := buildTestTree("R", map[string]int64{
+		"logs/app.log":     3000,
+		"data/a/big.bin":   50000,
+		"data/a/small.bin": 100,
+		"data/b/new.bin":   2000,
+		"README":           50,
+	})
+
+	d := diffTrees(before, after, true)
+
+	if d.delta() != 43500 {
+		t.Fatalf("Expected total delta of %d, found %d", 43500, d.delta())
+	}
+	expected := []struct {
+		path   string
+		change change
+		delta  int64
+	}{
+		{"data", grew, 42000},
+		{"data/a", grew, 40000},
+		{"data/a/big.bin", grew, 40000},
+		{"logs", grew, 1500},
+		{"logs/app.log", grew, 2000},
+		{"data/b", added, 2000},
+		{"data/b/new.bin", added, 2000},
+		{"logs/old.log", removed, -500},
+	}
+	changes := d.changes()
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, found %d", len(expected), len(changes))
+	}
+	for _, e := range expected {
+		found := false
+		for _, c := range changes {
+			if c.path() == e.path {
+				found = true
+				if c.change() != e.change || c.delta() != e.delta {
+					t.Errorf("Expected %s to have %s by %d, found %s by %d", e.path, e.change, e.delta, c.change(), c.delta())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s to have changed", e.path)
+		}
+	}
+	for i := 1; i < len(changes); i++ {
+		if abs(changes[i-1].delta()) < abs(changes[i].delta()) {
+			t.Errorf("Expected changes to be sorted by delta, found %s before %s", changes[i-1].path(), changes[i].path())
+		}
+	}
+	if d.children[0].name != "data" || d.children[len(d.children)-1].name != "README" {
+		t.Errorf("Expected children to be sorted by growth")
+	}
+}
+
+// Saves a scan of dir, in which the directory called locked couldn't be read.
+func saveScanWithLockedDirectory(t *testing.T, dir string, scanPath string) {
+	w, err := scan.Create(scanPath, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = walker.Walk(dir, walker.Options{Jobs: 1}, func(path string, info os.FileInfo, err error) error {
+		if info != nil && info.IsDir() && info.Name() == "locked" {
+			if err := w.Add(path, nil, os.ErrPermission); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		return w.Add(path, info, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Creates a directory with a file in a directory called locked.
+func createLockedTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "forest-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "locked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "locked", "secret"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestUnreadablePathsAreReported(t *testing.T) {
+	dir := createLockedTree(t)
+	defer os.RemoveAll(dir)
+	scanDir, err := ioutil.TempDir("", "forest-diff-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(scanDir)
+	scanPath := filepath.Join(scanDir, "old.scan")
+	saveScanWithLockedDirectory(t, dir, scanPath)
+
+	o := options{jobs: 1}
+	_, skipped := o.buildTree(scanPath)
+	if len(skipped.Paths) != 1 || skipped.Paths[0].Kind != walker.PermissionDenied {
+		t.Fatalf("Expected the locked directory to be skipped, found %v", skipped.Paths)
+	}
+	var out bytes.Buffer
+	o.reportSkipped(&out, skipped)
+	if !strings.Contains(out.String(), "Skipped 1 paths") || !strings.Contains(out.String(), "--show-errors") {
+		t.Errorf("Expected the skipped path to be counted, found %q", out.String())
+	}
+	out.Reset()
+	o.showErrors = true
+	o.reportSkipped(&out, skipped)
+	if !strings.Contains(out.String(), filepath.Join(dir, "locked")+": permission denied") {
+		t.Errorf("Expected the skipped path to be listed, found %q", out.String())
+	}
+}
+
+func TestUnreadableDirectoriesAreSkipped(t *testing.T) {
+	dir := createLockedTree(t)
+	defer os.RemoveAll(dir)
+	locked := filepath.Join(dir, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := ioutil.ReadDir(locked); err == nil {
+		t.Skip("Directories without permissions can still be read, such as by root")
+	}
+
+	o := options{jobs: 1}
+	if _, skipped := o.buildTree(dir); len(skipped.Paths) != 1 || skipped.Paths[0].Path != locked {
+		t.Errorf("Expected the locked directory to be skipped, found %v", skipped.Paths)
+	}
+}
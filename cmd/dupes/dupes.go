@@ -0,0 +1,177 @@
+package dupes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/walker"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+type options struct {
+	includeDotFiles bool
+	minSize         string
+	minBytes        int64
+	jobs            int
+	root            string
+}
+
+func (o *options) initialise(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		o.root = args[0]
+	} else {
+		o.root = "."
+	}
+	if includeDotFiles, _ := cmd.Flags().GetBool("include-hidden-files"); includeDotFiles {
+		o.includeDotFiles = includeDotFiles
+	}
+	if minSize, _ := cmd.Flags().GetString("min-size"); minSize != "" {
+		o.minSize = minSize
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.jobs = jobs
+	}
+}
+
+func (o *options) validate() {
+	if err := o.validatePath(os.Stat(o.root)); err != nil {
+		log.Fatal(err)
+	}
+	minBytes, err := formatter.ParseStorage(o.minSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Empty files are all the same, but they don't waste any space.
+	if minBytes < 1 {
+		minBytes = 1
+	}
+	o.minBytes = minBytes
+	if o.jobs < 1 {
+		log.Fatal("Number of jobs must be at least 1")
+	}
+}
+
+func (o *options) validatePath(info os.FileInfo, err error) error {
+	if os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", o.root))
+	}
+	return err
+}
+
+// collect walks the tree, and returns the files which are big enough to be compared. Hard links to
+// the same file aren't duplicates, so only one of them is kept.
+func (o *options) collect(skipped *walker.Skipped) []candidate {
+	var candidates []candidate
+	links := walker.NewLinks()
+	opts := walker.Options{Jobs: o.jobs, IncludeDotFiles: o.includeDotFiles}
+	err := walker.Walk(o.root, opts, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Info("Skipping unreadable path: " + path)
+			skipped.Add(path, err)
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() < o.minBytes {
+			return nil
+		}
+		if _, seen := links.Visit(info); seen {
+			log.Info("Skipping another link to the same file: " + path)
+			return nil
+		}
+		candidates = append(candidates, candidate{path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return candidates
+}
+
+func (o *options) run() {
+	log.Info("Finding duplicate files in directory:", o.root)
+	skipped := walker.Skipped{}
+	candidates := o.collect(&skipped)
+	f := finder{jobs: o.jobs}
+	groups := f.find(candidates)
+	skipped.Paths = append(skipped.Paths, f.skipped.Paths...)
+	o.print(groups)
+	if len(skipped.Paths) > 0 {
+		fmt.Fprintf(os.Stderr, "\nSkipped %d paths which couldn't be read.\n", len(skipped.Paths))
+		os.Exit(walker.ExitCodePartial)
+	}
+}
+
+func (o *options) print(groups []group) {
+	if len(groups) == 0 {
+		fmt.Println("\nNo duplicate files found.")
+		return
+	}
+	var numFiles int
+	var wasted int64
+	for _, g := range groups {
+		numFiles += len(g.paths)
+		wasted += g.wasted()
+	}
+	fmt.Println("\nDuplicate groups:", formatter.HumaniseNumber(int64(len(groups))))
+	fmt.Println("Duplicate files:", formatter.HumaniseNumber(int64(numFiles)))
+	fmt.Println("Space that could be reclaimed:", formatter.HumaniseStorage(wasted))
+	for _, g := range groups {
+		fmt.Printf(
+			"\n%d files of %s each (%s wasted):\n",
+			len(g.paths),
+			formatter.HumaniseStorage(g.size),
+			formatter.HumaniseStorage(g.wasted()),
+		)
+		for _, path := range g.paths {
+			fmt.Println("  " + o.relative(path))
+		}
+	}
+}
+
+// relative returns the path relative to the root being searched.
+func (o *options) relative(path string) string {
+	if rel, err := filepath.Rel(o.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+var cmd = &cobra.Command{
+	Use:   "dupes [path]",
+	Short: "Find files with identical content",
+}
+
+func NewDupesCmd() *cobra.Command {
+	o := options{}
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		o.initialise(cmd, args)
+		o.validate()
+		o.run()
+	}
+
+	cmd.Flags().BoolVarP(
+		&o.includeDotFiles,
+		"include-hidden-files",
+		"a",
+		false,
+		"include hidden files (default is false)",
+	)
+	cmd.Flags().StringVar(
+		&o.minSize,
+		"min-size",
+		"1",
+		"only compare files of at least this size, such as 100K or 1M",
+	)
+	cmd.Flags().IntVarP(
+		&o.jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories read, and files hashed, concurrently",
+	)
+
+	return cmd
+}
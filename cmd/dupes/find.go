@@ -0,0 +1,184 @@
+package dupes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/robinmitra/forest/walker"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Only the start of each file is hashed at first, which is usually enough to tell apart files of
+// the same size without reading all of them.
+const partialSize = 16 * 1024
+
+// A file which might have the same content as others.
+type candidate struct {
+	path string
+	size int64
+}
+
+// A group of files with identical content.
+type group struct {
+	size  int64
+	paths []string
+}
+
+// wasted returns the bytes that could be reclaimed by keeping only one of the files.
+func (g group) wasted() int64 {
+	return g.size * int64(len(g.paths)-1)
+}
+
+type finder struct {
+	// Maximum number of files hashed concurrently.
+	jobs int
+	// Files which couldn't be read while hashing them.
+	skipped walker.Skipped
+}
+
+// find groups the candidates with identical content. Files are compared by size first, then by a
+// hash of their start, and only then by a hash of their whole content, so that most files are never
+// read at all. Groups are sorted by the bytes they waste.
+func (f *finder) find(candidates []candidate) []group {
+	var sameSize [][]candidate
+	bySize := make(map[int64][]candidate)
+	var sizes []int64
+	for _, c := range candidates {
+		if _, ok := bySize[c.size]; !ok {
+			sizes = append(sizes, c.size)
+		}
+		bySize[c.size] = append(bySize[c.size], c)
+	}
+	for _, size := range sizes {
+		if len(bySize[size]) > 1 {
+			sameSize = append(sameSize, bySize[size])
+		}
+	}
+
+	var groups []group
+	for _, partial := range f.regroup(flatten(sameSize), partialHash) {
+		if partial[0].size <= partialSize {
+			// The whole file has been hashed already.
+			groups = append(groups, newGroup(partial))
+			continue
+		}
+		for _, full := range f.regroup(partial, fullHash) {
+			groups = append(groups, newGroup(full))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].wasted() == groups[j].wasted() {
+			return groups[i].paths[0] < groups[j].paths[0]
+		}
+		return groups[i].wasted() > groups[j].wasted()
+	})
+	return groups
+}
+
+func flatten(groups [][]candidate) []candidate {
+	var candidates []candidate
+	for _, g := range groups {
+		candidates = append(candidates, g...)
+	}
+	return candidates
+}
+
+func newGroup(candidates []candidate) group {
+	g := group{size: candidates[0].size}
+	for _, c := range candidates {
+		g.paths = append(g.paths, c.path)
+	}
+	sort.Strings(g.paths)
+	return g
+}
+
+// regroup hashes the candidates, and groups the ones with the same size and hash, leaving out the
+// ones which turn out to be unique.
+func (f *finder) regroup(candidates []candidate, hash func(path string) (string, error)) [][]candidate {
+	hashes := f.hashAll(candidates, hash)
+	var keys []string
+	byHash := make(map[string][]candidate)
+	for i, c := range candidates {
+		if hashes[i] == "" {
+			continue
+		}
+		key := fmt.Sprintf("%d:%s", c.size, hashes[i])
+		if _, ok := byHash[key]; !ok {
+			keys = append(keys, key)
+		}
+		byHash[key] = append(byHash[key], c)
+	}
+	var groups [][]candidate
+	for _, key := range keys {
+		if len(byHash[key]) > 1 {
+			groups = append(groups, byHash[key])
+		}
+	}
+	return groups
+}
+
+// hashAll hashes the candidates with a bounded number of workers, so that only so many files are
+// read at once. Files which can't be read are skipped, and get an empty hash.
+func (f *finder) hashAll(candidates []candidate, hash func(path string) (string, error)) []string {
+	hashes := make([]string, len(candidates))
+	errs := make([]error, len(candidates))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	jobs := f.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				hashes[i], errs[i] = hash(candidates[i].path)
+			}
+		}()
+	}
+	for i := range candidates {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	// Errors are recorded afterwards and in order, since Skipped isn't safe for concurrent use.
+	for i, err := range errs {
+		if err != nil {
+			f.skipped.Add(candidates[i].path, err)
+			hashes[i] = ""
+		}
+	}
+	return hashes
+}
+
+func partialHash(path string) (string, error) {
+	return hashFile(path, partialSize)
+}
+
+func fullHash(path string) (string, error) {
+	return hashFile(path, -1)
+}
+
+// hashFile returns the SHA-256 hash of the first limit bytes of a file, or all of it if limit is
+// negative.
+func hashFile(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if limit < 0 {
+		_, err = io.Copy(h, file)
+	} else {
+		_, err = io.CopyN(h, file, limit)
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
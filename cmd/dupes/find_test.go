@@ -0,0 +1,94 @@
+package dupes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string][]byte) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindGroupsIdenticalFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "forest-dupes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	big := make([]byte, partialSize*3)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	// Only differs after the part that's hashed first.
+	almost := append([]byte{}, big...)
+	almost[len(almost)-1]++
+	writeFiles(t, root, map[string][]byte{
+		"big1":   big,
+		"big2":   big,
+		"almost": almost,
+		"small1": []byte("hello"),
+		"small2": []byte("hello"),
+		"small3": []byte("hello"),
+		"other":  []byte("world"),
+		"unique": []byte("nothing else is this size"),
+	})
+
+	opts := options{root: root, minBytes: 1, jobs: 4}
+	f := finder{jobs: 4}
+	groups := f.find(opts.collect(&f.skipped))
+
+	expected := []group{
+		{size: int64(len(big)), paths: []string{filepath.Join(root, "big1"), filepath.Join(root, "big2")}},
+		{size: 5, paths: []string{
+			filepath.Join(root, "small1"),
+			filepath.Join(root, "small2"),
+			filepath.Join(root, "small3"),
+		}},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected groups %v, found %v", expected, groups)
+	}
+	if groups[0].wasted() != int64(len(big)) || groups[1].wasted() != 10 {
+		t.Errorf("Unexpected wasted bytes %d and %d", groups[0].wasted(), groups[1].wasted())
+	}
+}
+
+func TestFindSkipsHardLinksAndSmallFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "forest-dupes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string][]byte{"a": []byte("same"), "b": []byte("same")})
+	if err := os.Link(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("Hard links are not supported: %s", err)
+	}
+
+	opts := options{root: root, minBytes: 1, jobs: 1}
+	f := finder{jobs: 1}
+	groups := f.find(opts.collect(&f.skipped))
+	if len(groups) != 1 || len(groups[0].paths) != 2 {
+		t.Errorf("Expected links to the same file to be counted once, found %v", groups)
+	}
+
+	opts.minBytes = 5
+	if groups := f.find(opts.collect(&f.skipped)); len(groups) != 0 {
+		t.Errorf("Expected files smaller than the minimum size to be left out, found %v", groups)
+	}
+}
+
+func TestUnreadableFilesAreSkipped(t *testing.T) {
+	f := finder{jobs: 2}
+	groups := f.find([]candidate{{path: "/does/not/exist/1", size: 10}, {path: "/does/not/exist/2", size: 10}})
+	if len(groups) != 0 || len(f.skipped.Paths) != 2 {
+		t.Errorf("Expected missing files to be skipped, found %v and %v", groups, f.skipped.Paths)
+	}
+}
@@ -0,0 +1,367 @@
+package report
+
+import (
+	"fmt"
+	"github.com/robinmitra/forest/cmd/analyse"
+	"github.com/robinmitra/forest/formatter"
+	"github.com/robinmitra/forest/walker"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many files are kept in each directory of the report's tree. The rest are folded into a
+// single entry, so that reports of large trees stay small enough to open in a browser.
+const reportFilesPerDirectory = 100
+
+type options struct {
+	analyse.Options
+	html string
+}
+
+func (o *options) initialise(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		o.Roots = args
+	} else {
+		o.Roots = []string{"."}
+	}
+	if html, _ := cmd.Flags().GetString("html"); html != "" {
+		o.html = html
+	}
+	if includeDotFiles, _ := cmd.Flags().GetBool("include-hidden-files"); includeDotFiles {
+		o.IncludeDotFiles = includeDotFiles
+	}
+	if apparentSize, _ := cmd.Flags().GetBool("apparent-size"); apparentSize {
+		o.ApparentSize = apparentSize
+	}
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		o.From = from
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.Jobs = jobs
+	}
+	if top, _ := cmd.Flags().GetInt("top"); top >= 0 {
+		o.Top = top
+	}
+	if depth, _ := cmd.Flags().GetInt("depth"); depth >= 0 {
+		o.Depth = depth
+	}
+	if exclude, _ := cmd.Flags().GetStringArray("exclude"); len(exclude) > 0 {
+		o.Filters.Exclude = exclude
+	}
+	if include, _ := cmd.Flags().GetStringArray("include"); len(include) > 0 {
+		o.Filters.Include = include
+	}
+	if excludeFrom, _ := cmd.Flags().GetString("exclude-from"); excludeFrom != "" {
+		o.Filters.ExcludeFrom = excludeFrom
+	}
+	if respectGitignore, _ := cmd.Flags().GetBool("respect-gitignore"); respectGitignore {
+		o.Filters.RespectGitignore = respectGitignore
+	}
+}
+
+// The rest of the options are checked along with the analysis.
+func (o *options) validate() {
+	if o.html == "" {
+		log.Fatal("The file to write the report to must be given with --html")
+	}
+}
+
+func (o *options) run() {
+	log.Info("Reporting on directories:", strings.Join(o.Roots, ", "))
+	result := analyse.Analyse(o.Options)
+	f, err := os.Create(o.html)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := writeReport(f, result, time.Now()); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Report written to %s\n", o.html)
+	// Exits with a distinct code when some paths were skipped, as analyse does.
+	if result.Partial() {
+		os.Exit(walker.ExitCodePartial)
+	}
+}
+
+// A file or directory in the report's tree, which is embedded in the page as JSON.
+type reportNode struct {
+	Name     string        `json:"name"`
+	Size     int64         `json:"size"`
+	Dir      bool          `json:"dir,omitempty"`
+	Files    int           `json:"files,omitempty"`
+	Children []*reportNode `json:"children,omitempty"`
+}
+
+// buildReportTree puts the directories and files of an analysis back together into a tree, with
+// the largest entries first.
+func buildReportTree(r *analyse.Result) *reportNode {
+	totals := r.Totals()
+	root := &reportNode{
+		Name:  filepath.Base(r.Root()),
+		Size:  r.Usage(),
+		Dir:   true,
+		Files: totals.Files,
+	}
+	nodes := map[string]*reportNode{".": root}
+	if roots := r.Roots(); len(roots) > 1 {
+		// Each root is shown below a root standing for all of them.
+		root.Name = fmt.Sprintf("%d roots", len(roots))
+		nodes = make(map[string]*reportNode)
+		for _, total := range roots {
+			node := &reportNode{
+				Name:  total.Path,
+				Size:  total.Usage,
+				Dir:   true,
+				Files: total.Files,
+			}
+			nodes[filepath.Clean(total.Path)] = node
+			root.Children = append(root.Children, node)
+		}
+	}
+	// Parents are created before their children, whatever order the directories were walked in.
+	dirs := r.Directories()
+	sort.SliceStable(dirs, func(i, j int) bool { return dirs[i].Depth < dirs[j].Depth })
+	for _, dir := range dirs {
+		node := &reportNode{
+			Name:  dir.Name,
+			Size:  dir.Usage,
+			Dir:   true,
+			Files: dir.Files,
+		}
+		nodes[dir.Path] = node
+		parent, ok := nodes[filepath.Dir(dir.Path)]
+		if !ok {
+			parent = root
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	files := map[*reportNode][]*reportNode{}
+	for _, f := range r.Files() {
+		parent, ok := nodes[filepath.Dir(f.Path)]
+		if !ok {
+			parent = root
+		}
+		files[parent] = append(files[parent], &reportNode{Name: f.Name, Size: f.Usage})
+	}
+	for _, node := range nodes {
+		children := files[node]
+		sortReportNodes(children)
+		var rest *reportNode
+		if len(children) > reportFilesPerDirectory {
+			rest = &reportNode{Name: fmt.Sprintf("(%d more files)", len(children)-reportFilesPerDirectory)}
+			for _, child := range children[reportFilesPerDirectory:] {
+				rest.Size += child.Size
+			}
+			children = children[:reportFilesPerDirectory]
+		}
+		node.Children = append(node.Children, children...)
+		sortReportNodes(node.Children)
+		// Whatever was folded away comes last, however large it is.
+		if rest != nil {
+			node.Children = append(node.Children, rest)
+		}
+	}
+	return root
+}
+
+func sortReportNodes(nodes []*reportNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Size != nodes[j].Size {
+			return nodes[i].Size > nodes[j].Size
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
+
+type reportRow struct {
+	Name  string
+	Value string
+	Extra string
+}
+
+type reportTable struct {
+	Title   string
+	Headers []string
+	Rows    []reportRow
+}
+
+// What the report's template is filled in with.
+type reportPage struct {
+	Root      string
+	Generated string
+	Usage     string
+	Totals    []reportRow
+	Tables    []reportTable
+	Skipped   []reportRow
+	Tree      *reportNode
+}
+
+func newReportPage(r *analyse.Result, generated time.Time) reportPage {
+	totals := r.Totals()
+	page := reportPage{
+		Root:      r.Root(),
+		Generated: formatter.FormatTime(generated),
+		Usage:     r.UsageLabel(),
+		Totals: []reportRow{
+			{Name: "Files", Value: formatter.HumaniseNumber(int64(totals.Files))},
+			{Name: "Directories", Value: formatter.HumaniseNumber(int64(totals.Directories))},
+			{Name: "Disk usage", Value: formatter.HumaniseStorage(totals.DiskUsage)},
+			{Name: "Apparent size", Value: formatter.HumaniseStorage(totals.Size)},
+		},
+		Tree: buildReportTree(r),
+	}
+	if totals.HardLinks > 0 {
+		page.Totals = append(page.Totals, reportRow{
+			Name: "Hard-linked files",
+			Value: fmt.Sprintf(
+				"%s (%s counted only once)",
+				formatter.HumaniseNumber(int64(totals.HardLinks)),
+				formatter.HumaniseStorage(totals.HardLinksSaved),
+			),
+		})
+	}
+	for _, p := range r.Skipped().Paths {
+		page.Skipped = append(page.Skipped, reportRow{Name: p.Path, Value: p.Kind.String(), Extra: p.Err.Error()})
+	}
+
+	byCount := reportTable{
+		Title:   fmt.Sprintf("%s file types by occurrence", r.TopLabel()),
+		Headers: []string{"File type", "Occurrence"},
+	}
+	for _, ext := range r.TopExtensionsByCount() {
+		byCount.Rows = append(byCount.Rows, reportRow{Name: ext.Name, Value: formatter.HumaniseNumber(int64(ext.Files))})
+	}
+	bySize := reportTable{
+		Title:   fmt.Sprintf("%s file types by total %s", r.TopLabel(), r.UsageLabel()),
+		Headers: []string{"File type", "Size"},
+	}
+	for _, ext := range r.TopExtensionsByUsage() {
+		bySize.Rows = append(bySize.Rows, reportRow{Name: ext.Name, Value: formatter.HumaniseStorage(ext.Usage)})
+	}
+	files := reportTable{
+		Title:   fmt.Sprintf("%s files by %s", r.TopLabel(), r.UsageLabel()),
+		Headers: []string{"File", "Size", "Last modified"},
+	}
+	for _, f := range r.TopFiles() {
+		files.Rows = append(files.Rows, reportRow{
+			Name:  f.Path,
+			Value: formatter.HumaniseStorage(f.Usage),
+			Extra: formatter.FormatTime(f.ModTime),
+		})
+	}
+	dirs := reportTable{
+		Title:   fmt.Sprintf("%s directories%s by total %s", r.TopLabel(), r.DepthLabel(), r.UsageLabel()),
+		Headers: []string{"Directory", "Size", "Files"},
+	}
+	for _, dir := range r.TopDirectories() {
+		dirs.Rows = append(dirs.Rows, reportRow{
+			Name:  dir.Path,
+			Value: formatter.HumaniseStorage(dir.Usage),
+			Extra: formatter.HumaniseNumber(int64(dir.Files)),
+		})
+	}
+	page.Tables = []reportTable{byCount, bySize, files, dirs}
+	return page
+}
+
+// writeReport writes a single HTML page, with everything it needs embedded, so that it can be
+// opened anywhere without a network connection.
+func writeReport(w io.Writer, r *analyse.Result, generated time.Time) error {
+	return reportTemplate.Execute(w, newReportPage(r, generated))
+}
+
+var cmd = &cobra.Command{
+	Use:   "report [path...]",
+	Short: "Write a self-contained HTML report of directories and files",
+}
+
+func NewReportCmd() *cobra.Command {
+	o := options{}
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		o.initialise(cmd, args)
+		o.validate()
+		o.run()
+	}
+
+	cmd.Flags().StringVar(
+		&o.html,
+		"html",
+		"",
+		"file to write the HTML report to",
+	)
+	cmd.Flags().BoolVarP(
+		&o.IncludeDotFiles,
+		"include-hidden-files",
+		"a",
+		false,
+		"include hidden files (default is false)",
+	)
+	cmd.Flags().BoolVar(
+		&o.ApparentSize,
+		"apparent-size",
+		false,
+		"use apparent sizes rather than disk usage for totals and sorting",
+	)
+	cmd.Flags().StringVar(
+		&o.From,
+		"from",
+		"",
+		"report on a scan saved by the snapshot command, rather than the filesystem",
+	)
+	cmd.Flags().IntVarP(
+		&o.Jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories to read concurrently",
+	)
+	cmd.Flags().IntVar(
+		&o.Top,
+		"top",
+		10,
+		"number of rows in each table of the report, or 0 for all of them",
+	)
+	cmd.Flags().IntVar(
+		&o.Depth,
+		"depth",
+		0,
+		"only rank directories up to this many levels below the path, or 0 for all of them",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.Filters.Exclude,
+		"exclude",
+		nil,
+		"exclude files and directories matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.Filters.Include,
+		"include",
+		nil,
+		"only include files matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringVar(
+		&o.Filters.ExcludeFrom,
+		"exclude-from",
+		"",
+		"exclude files and directories matching the glob patterns listed in a file",
+	)
+	cmd.Flags().BoolVar(
+		&o.Filters.RespectGitignore,
+		"respect-gitignore",
+		false,
+		"exclude files and directories ignored by .gitignore files",
+	)
+
+	return cmd
+}
@@ -0,0 +1,99 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/robinmitra/forest/cmd/analyse"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTree creates a temporary directory holding files of the given sizes, by path.
+func createTree(t *testing.T, sizes map[string]int) string {
+	root, err := ioutil.TempDir("", "forest-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, size := range sizes {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func analyseTree(root string) *analyse.Result {
+	return analyse.Analyse(analyse.Options{Roots: []string{root}, ApparentSize: true, Jobs: 1, Top: 10})
+}
+
+func TestBuildReportTree(t *testing.T) {
+	root := createTree(t, map[string]int{"a/file1": 100, "a/b/file2": 400, "file3": 600})
+	defer os.RemoveAll(root)
+
+	tree := buildReportTree(analyseTree(root))
+	if tree.Name != filepath.Base(root) || tree.Size != 1100 || tree.Files != 3 {
+		t.Fatalf("Expected the root to hold 3 files of 1100 bytes, found %+v", tree)
+	}
+	if len(tree.Children) != 2 || tree.Children[0].Name != "file3" || tree.Children[1].Name != "a" {
+		t.Fatalf("Expected file3 then a below the root, found %+v", tree.Children)
+	}
+	a := tree.Children[1]
+	if !a.Dir || a.Size != 500 || a.Files != 2 {
+		t.Errorf("Expected a to hold 2 files of 500 bytes, found %+v", a)
+	}
+	if len(a.Children) != 2 || a.Children[0].Name != "b" || a.Children[0].Children[0].Name != "file2" {
+		t.Errorf("Expected b, holding file2, to come first in a, found %+v", a.Children)
+	}
+}
+
+func TestReportTreeFoldsSmallFiles(t *testing.T) {
+	sizes := make(map[string]int)
+	for i := 0; i < reportFilesPerDirectory+5; i++ {
+		sizes[fmt.Sprintf("file%d", i)] = 1000 - i
+	}
+	root := createTree(t, sizes)
+	defer os.RemoveAll(root)
+
+	tree := buildReportTree(analyseTree(root))
+	if len(tree.Children) != reportFilesPerDirectory+1 {
+		t.Fatalf("Expected %d entries, found %d", reportFilesPerDirectory+1, len(tree.Children))
+	}
+	rest := tree.Children[len(tree.Children)-1]
+	// The 5 smallest files are 1000-100 down to 1000-104 bytes.
+	if rest.Name != "(5 more files)" || rest.Size != 900+899+898+897+896 {
+		t.Errorf("Expected the smallest files to be folded together, found %+v", rest)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	root := createTree(t, map[string]int{"<script>.txt": 100, "notes.md": 200})
+	defer os.RemoveAll(root)
+
+	var out bytes.Buffer
+	if err := writeReport(&out, analyseTree(root), time.Date(2026, 1, 2, 3, 4, 0, 0, time.Local)); err != nil {
+		t.Fatalf("Unexpected error writing the report: %s", err)
+	}
+	page := out.String()
+	for _, expected := range []string{"Forest report: " + root, "Generated 2026-01-02 03:04", "notes.md", ".md", "300 B", "Top 10 files by apparent size"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the report to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<script>.txt") {
+		t.Error("Expected file names to be escaped")
+	}
+	// Nothing may be loaded from elsewhere, so that the report works offline.
+	for _, external := range []string{"http://", "https://", "src=", "<link"} {
+		if strings.Contains(page, external) {
+			t.Errorf("Expected the report to be self-contained, found %q", external)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package report
+
+import "html/template"
+
+// The page is entirely self-contained: styles, scripts and data are all inline, and nothing is
+// loaded from elsewhere.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Forest report: {{.Root}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: 0.2em; }
+h3 { font-size: 1em; margin-bottom: 0.4em; }
+.meta { color: #777; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { text-align: left; padding: 0.2em 1.2em 0.2em 0; }
+th { border-bottom: 1px solid #ddd; }
+td.num { text-align: right; }
+.tables { display: flex; flex-wrap: wrap; gap: 0 3em; }
+.tree ul { list-style: none; margin: 0; padding-left: 1.2em; }
+.tree > ul { padding-left: 0; }
+.tree .row { display: flex; align-items: center; cursor: default; white-space: nowrap; }
+.tree .row:hover { background: #f3f3f3; }
+.tree .toggle { width: 1.2em; color: #777; cursor: pointer; }
+.tree .size { width: 7em; text-align: right; margin-right: 0.8em; font-variant-numeric: tabular-nums; }
+.tree .bar { width: 80px; height: 0.7em; background: #eee; margin-right: 0.8em; }
+.tree .bar span { display: block; height: 100%; background: #5a9; }
+.tree .dir { cursor: pointer; font-weight: bold; }
+#crumbs a { color: #36c; cursor: pointer; }
+#map { position: relative; height: 480px; border: 1px solid #ccc; overflow: hidden; }
+#map div { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden;
+  font-size: 0.8em; padding: 2px 4px; color: #fff; white-space: nowrap; }
+#map div.dir { cursor: zoom-in; }
+</style>
+</head>
+<body>
+<h1>Forest report: {{.Root}}</h1>
+<div class="meta">Generated {{.Generated}}. Sizes are {{.Usage}}.</div>
+
+<h2>Summary</h2>
+<table>
+{{range .Totals}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Skipped}}<h3>Skipped paths</h3>
+<table>
+<tr><th>Path</th><th>Reason</th><th>Error</th></tr>
+{{range .Skipped}}<tr><td>{{.Name}}</td><td>{{.Value}}</td><td>{{.Extra}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Statistics</h2>
+<div class="tables">
+{{range .Tables}}<div>
+<h3>{{.Title}}</h3>
+<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td>{{if .Extra}}<td class="num">{{.Extra}}</td>{{end}}</tr>
+{{end}}</table>
+</div>
+{{end}}</div>
+
+<h2>Treemap</h2>
+<div id="crumbs"></div>
+<div id="map"></div>
+
+<h2>Tree</h2>
+<div class="tree" id="tree"></div>
+
+<script>
+var tree = {{.Tree}};
+var colours = ["#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"];
+
+function humanise(bytes) {
+  var units = [["GB", 1073741824], ["MB", 1048576], ["KB", 1024]];
+  for (var i = 0; i < units.length; i++) {
+    if (bytes >= units[i][1]) {
+      return (bytes / units[i][1]).toLocaleString("en", {minimumFractionDigits: 2, maximumFractionDigits: 2}) + " " + units[i][0];
+    }
+  }
+  return bytes.toLocaleString("en") + " B";
+}
+
+function link(node, parent) {
+  node.parent = parent;
+  (node.children || []).forEach(function (child) { link(child, node); });
+}
+link(tree, null);
+
+function element(tag, className, text) {
+  var el = document.createElement(tag);
+  if (className) { el.className = className; }
+  if (text !== undefined) { el.textContent = text; }
+  return el;
+}
+
+// The tree only builds the children of a directory when it's first expanded, so that large trees
+// open quickly.
+function treeItem(node) {
+  var li = element("li");
+  var row = element("div", "row");
+  var hasChildren = node.children && node.children.length > 0;
+  var toggle = element("span", "toggle", hasChildren ? "▸" : "");
+  var bar = element("span", "bar");
+  var fill = element("span");
+  fill.style.width = (tree.size > 0 ? 100 * node.size / tree.size : 0) + "%";
+  bar.appendChild(fill);
+  var name = element("span", node.dir ? "dir" : "", node.dir ? node.name + "/" : node.name);
+  if (node.dir) { name.title = (node.files || 0) + " files"; }
+  row.appendChild(toggle);
+  row.appendChild(element("span", "size", humanise(node.size)));
+  row.appendChild(bar);
+  row.appendChild(name);
+  li.appendChild(row);
+  if (hasChildren) {
+    var list = null;
+    var expand = function () {
+      if (list === null) {
+        list = element("ul");
+        node.children.forEach(function (child) { list.appendChild(treeItem(child)); });
+        li.appendChild(list);
+      } else {
+        list.hidden = !list.hidden;
+      }
+      toggle.textContent = list.hidden ? "▸" : "▾";
+    };
+    toggle.onclick = expand;
+    name.onclick = expand;
+  }
+  return li;
+}
+
+function worst(row, side) {
+  var sum = 0, max = 0, min = Infinity;
+  row.forEach(function (a) { sum += a; max = Math.max(max, a); min = Math.min(min, a); });
+  return Math.max(side * side * max / (sum * sum), sum * sum / (side * side * min));
+}
+
+// squarify lays out values, largest first, as rectangles filling the given one, keeping them as
+// close to square as it can.
+function squarify(values, x, y, w, h) {
+  var total = values.reduce(function (a, b) { return a + b; }, 0);
+  var areas = values.map(function (v) { return v * w * h / total; });
+  var rects = [];
+  var i = 0;
+  while (i < areas.length) {
+    var side = Math.min(w, h);
+    var row = [areas[i]];
+    var j = i + 1;
+    while (j < areas.length && worst(row.concat([areas[j]]), side) <= worst(row, side)) {
+      row.push(areas[j]);
+      j++;
+    }
+    var sum = row.reduce(function (a, b) { return a + b; }, 0);
+    if (w >= h) {
+      var width = sum / h, top = y;
+      row.forEach(function (a) { rects.push({x: x, y: top, w: width, h: a / width}); top += a / width; });
+      x += width;
+      w -= width;
+    } else {
+      var height = sum / w, left = x;
+      row.forEach(function (a) { rects.push({x: left, y: y, w: a / height, h: height}); left += a / height; });
+      y += height;
+      h -= height;
+    }
+    i = j;
+  }
+  return rects;
+}
+
+var current = tree;
+
+function drawMap(node) {
+  current = node;
+  var map = document.getElementById("map");
+  var crumbs = document.getElementById("crumbs");
+  map.innerHTML = "";
+  crumbs.innerHTML = "";
+  var path = [];
+  for (var n = node; n !== null; n = n.parent) { path.unshift(n); }
+  path.forEach(function (n, i) {
+    if (i > 0) { crumbs.appendChild(document.createTextNode(" / ")); }
+    if (n === node) {
+      crumbs.appendChild(element("span", "", n.name + " (" + humanise(n.size) + ")"));
+    } else {
+      var a = element("a", "", n.name);
+      a.onclick = function () { drawMap(n); };
+      crumbs.appendChild(a);
+    }
+  });
+  var children = (node.children || []).filter(function (c) { return c.size > 0; });
+  if (children.length === 0) { return; }
+  var rects = squarify(children.map(function (c) { return c.size; }), 0, 0, map.clientWidth, map.clientHeight);
+  children.forEach(function (child, i) {
+    var r = rects[i];
+    if (r.w < 1 || r.h < 1) { return; }
+    var tile = element("div", child.dir ? "dir" : "", r.w > 40 && r.h > 14 ? child.name : "");
+    tile.style.left = r.x + "px";
+    tile.style.top = r.y + "px";
+    tile.style.width = r.w + "px";
+    tile.style.height = r.h + "px";
+    tile.style.background = colours[i % colours.length];
+    tile.title = child.name + (child.dir ? "/" : "") + " " + humanise(child.size);
+    if (child.dir && child.children) {
+      tile.onclick = function () { drawMap(child); };
+    }
+    map.appendChild(tile);
+  });
+}
+
+var root = element("ul");
+root.appendChild(treeItem(tree));
+document.getElementById("tree").appendChild(root);
+var toggle = root.querySelector(".toggle");
+if (toggle.onclick) { toggle.onclick(); }
+drawMap(tree);
+window.onresize = function () { drawMap(current); };
+</script>
+</body>
+</html>
+`))
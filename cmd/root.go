@@ -3,6 +3,10 @@ package cmd
 import (
 	"github.com/robinmitra/forest/cmd/analyse"
 	"github.com/robinmitra/forest/cmd/browse"
+	"github.com/robinmitra/forest/cmd/diff"
+	"github.com/robinmitra/forest/cmd/dupes"
+	"github.com/robinmitra/forest/cmd/report"
+	"github.com/robinmitra/forest/cmd/snapshot"
 	"github.com/robinmitra/forest/cmd/version"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -47,8 +51,12 @@ func NewRootCmd() *cobra.Command {
 	cmd.PersistentFlags().BoolVarP(&o.verbose, "verbose", "v", false, "verbose output")
 
 	cmd.AddCommand(analyse.NewAnalyseCmd())
+	cmd.AddCommand(report.NewReportCmd())
 	cmd.AddCommand(version.NewVersionCmd(VERSION))
 	cmd.AddCommand(browse.NewInteractiveCmd())
+	cmd.AddCommand(diff.NewDiffCmd())
+	cmd.AddCommand(snapshot.NewSnapshotCmd())
+	cmd.AddCommand(dupes.NewDupesCmd())
 
 	return cmd
 }
@@ -0,0 +1,166 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/robinmitra/forest/filter"
+	"github.com/robinmitra/forest/scan"
+	"github.com/robinmitra/forest/walker"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+type options struct {
+	includeDotFiles bool
+	jobs            int
+	output          string
+	filters         filter.Options
+	filter          *filter.Filter
+	root            string
+}
+
+func (o *options) initialise(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		o.root = args[0]
+	} else {
+		o.root = "."
+	}
+	if includeDotFiles, _ := cmd.Flags().GetBool("include-hidden-files"); includeDotFiles {
+		o.includeDotFiles = includeDotFiles
+	}
+	if jobs, _ := cmd.Flags().GetInt("jobs"); jobs > 0 {
+		o.jobs = jobs
+	}
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		o.output = output
+	}
+	if exclude, _ := cmd.Flags().GetStringArray("exclude"); len(exclude) > 0 {
+		o.filters.Exclude = exclude
+	}
+	if include, _ := cmd.Flags().GetStringArray("include"); len(include) > 0 {
+		o.filters.Include = include
+	}
+	if excludeFrom, _ := cmd.Flags().GetString("exclude-from"); excludeFrom != "" {
+		o.filters.ExcludeFrom = excludeFrom
+	}
+	if respectGitignore, _ := cmd.Flags().GetBool("respect-gitignore"); respectGitignore {
+		o.filters.RespectGitignore = respectGitignore
+	}
+}
+
+func (o *options) validate() {
+	if err := o.validatePath(os.Stat(o.root)); err != nil {
+		log.Fatal(err)
+	}
+	if o.output == "" {
+		log.Fatal("The file to save the scan to must be specified with --output")
+	}
+	if o.jobs < 1 {
+		log.Fatal("Number of jobs must be at least 1")
+	}
+	// The filter is built up front, so that bad patterns are reported before walking.
+	f, err := filter.New(o.root, o.filters)
+	if err != nil {
+		log.Fatal(err)
+	}
+	o.filter = f
+}
+
+func (o *options) validatePath(info os.FileInfo, err error) error {
+	if os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("Directory \"%s\" does not exist", o.root))
+	}
+	return err
+}
+
+func (o *options) run() {
+	log.Info("Saving scan of directory:", o.root)
+	w, err := scan.Create(o.output, o.root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	skipped := walker.Skipped{}
+	opts := walker.Options{Jobs: o.jobs, IncludeDotFiles: o.includeDotFiles, Skip: o.filter.Skip}
+	err = walker.Walk(o.root, opts, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Unreadable paths are saved too, so that they're reported when the scan is used.
+			skipped.Add(path, err)
+		}
+		return w.Add(path, info, err)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Saved %d files and directories to %s.\n", w.Count()-len(skipped.Paths), o.output)
+	if len(skipped.Paths) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d paths which couldn't be read.\n", len(skipped.Paths))
+		os.Exit(walker.ExitCodePartial)
+	}
+}
+
+var cmd = &cobra.Command{
+	Use:   "snapshot [path]",
+	Short: "Save a scan of directories and files, to analyse or browse later",
+}
+
+func NewSnapshotCmd() *cobra.Command {
+	o := options{}
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		o.initialise(cmd, args)
+		o.validate()
+		o.run()
+	}
+
+	cmd.Flags().StringVarP(
+		&o.output,
+		"output",
+		"o",
+		"",
+		"file to save the scan to, such as scan.forest",
+	)
+	cmd.Flags().BoolVarP(
+		&o.includeDotFiles,
+		"include-hidden-files",
+		"a",
+		false,
+		"include hidden files (default is false)",
+	)
+	cmd.Flags().IntVarP(
+		&o.jobs,
+		"jobs",
+		"j",
+		walker.DefaultJobs,
+		"number of directories to read concurrently",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Exclude,
+		"exclude",
+		nil,
+		"exclude files and directories matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringArrayVar(
+		&o.filters.Include,
+		"include",
+		nil,
+		"only include files matching a glob pattern (can be repeated)",
+	)
+	cmd.Flags().StringVar(
+		&o.filters.ExcludeFrom,
+		"exclude-from",
+		"",
+		"exclude files and directories matching the glob patterns listed in a file",
+	)
+	cmd.Flags().BoolVar(
+		&o.filters.RespectGitignore,
+		"respect-gitignore",
+		false,
+		"exclude files and directories ignored by .gitignore files",
+	)
+
+	return cmd
+}
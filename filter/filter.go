@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Options specifies which files and directories to leave out.
+type Options struct {
+	// Patterns of files and directories to exclude.
+	Exclude []string
+	// Patterns of files to include. When any are given, all the other files are excluded.
+	Include []string
+	// Path to a file listing patterns to exclude, one per line.
+	ExcludeFrom string
+	// Whether to exclude whatever .gitignore files within the tree ignore.
+	RespectGitignore bool
+}
+
+// Filter decides which files and directories under a root are left out. It's safe for concurrent
+// use.
+type Filter struct {
+	root      string
+	excludes  []pattern
+	includes  []pattern
+	gitignore bool
+	// Patterns from the .gitignore file of each directory, loaded lazily.
+	mu      sync.Mutex
+	ignores map[string][]pattern
+}
+
+// New creates a filter for the tree rooted at root.
+func New(root string, opts Options) (*Filter, error) {
+	f := &Filter{
+		root:      root,
+		gitignore: opts.RespectGitignore,
+		ignores:   make(map[string][]pattern),
+	}
+	excludes := opts.Exclude
+	if opts.ExcludeFrom != "" {
+		lines, err := readPatterns(opts.ExcludeFrom)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, lines...)
+	}
+	for _, e := range excludes {
+		p, err := parsePattern(e)
+		if err != nil {
+			return nil, err
+		}
+		f.excludes = append(f.excludes, p)
+	}
+	for _, i := range opts.Include {
+		p, err := parsePattern(i)
+		if err != nil {
+			return nil, err
+		}
+		f.includes = append(f.includes, p)
+	}
+	return f, nil
+}
+
+// Skip reports whether the file or directory at path should be left out.
+func (f *Filter) Skip(path string, info os.FileInfo) bool {
+	rel, err := f.rel(path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	isDir := info.IsDir()
+
+	if matched, negated := matchLast(f.excludes, rel, isDir); matched && !negated {
+		return true
+	}
+	if f.gitignore && f.ignored(rel, isDir) {
+		return true
+	}
+	if len(f.includes) > 0 && !isDir {
+		if matched, negated := matchLast(f.includes, rel, isDir); !matched || negated {
+			return true
+		}
+	}
+	return false
+}
+
+// rel returns path relative to the root. Paths are made absolute first when only one of them is,
+// such as for a list of absolute paths filtered against the working directory.
+func (f *Filter) rel(path string) (string, error) {
+	root := f.root
+	if filepath.IsAbs(root) != filepath.IsAbs(path) {
+		var err error
+		if root, err = filepath.Abs(root); err != nil {
+			return "", err
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Rel(root, path)
+}
+
+// Checks the .gitignore files of every directory above the path, where the deepest one wins.
+func (f *Filter) ignored(rel string, isDir bool) bool {
+	segments := strings.Split(rel, "/")
+	if isDir && segments[len(segments)-1] == ".git" {
+		return true
+	}
+	ignored := false
+	for i := 0; i < len(segments); i++ {
+		dir := strings.Join(segments[:i], "/")
+		patterns := f.loadGitignore(dir)
+		if len(patterns) == 0 {
+			continue
+		}
+		if matched, negated := matchLast(patterns, strings.Join(segments[i:], "/"), isDir); matched {
+			ignored = !negated
+		}
+	}
+	return ignored
+}
+
+func (f *Filter) loadGitignore(dir string) []pattern {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if patterns, ok := f.ignores[dir]; ok {
+		return patterns
+	}
+	var patterns []pattern
+	lines, _ := readPatterns(filepath.Join(f.root, filepath.FromSlash(dir), ".gitignore"))
+	for _, l := range lines {
+		// Git ignores invalid patterns too, rather than refusing to work.
+		if p, err := parsePattern(l); err == nil {
+			patterns = append(patterns, p)
+		}
+	}
+	f.ignores[dir] = patterns
+	return patterns
+}
+
+// Reads patterns from a file, leaving out blank lines and comments.
+func readPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
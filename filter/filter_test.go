@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fileInfoMock struct {
+	dir      bool
+	basename string
+}
+
+func (f fileInfoMock) Name() string       { return f.basename }
+func (f fileInfoMock) ModTime() time.Time { return time.Time{} }
+func (f fileInfoMock) IsDir() bool        { return f.dir }
+func (f fileInfoMock) Size() int64        { return int64(0) }
+func (f fileInfoMock) Mode() os.FileMode {
+	if f.dir {
+		return 0755 | os.ModeDir
+	}
+	return 0644
+}
+func (f fileInfoMock) Sys() interface{} { return nil }
+
+func TestPatternMatching(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		match   bool
+	}{
+		{"*.log", "app.log", false, true},
+		{"*.log", "var/log/app.log", false, true},
+		{"*.log", "app.txt", false, false},
+		{"build/*.o", "build/main.o", false, true},
+		{"build/*.o", "src/build/main.o", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"src/**/test", "src/test", true, true},
+		{"src/**/test", "src/a/b/test", true, true},
+		{"src/**/test", "lib/a/test", true, false},
+		{"**/node_modules", "a/b/node_modules", true, true},
+		{"vendor/", "vendor", true, true},
+		{"vendor/", "vendor", false, false},
+		{"logs/**", "logs/2019/01/app.log", false, true},
+	}
+	for _, tc := range testCases {
+		// Bind the current test case as otherwise `tc` will end up referring to the last one.
+		tc := tc
+		t.Run(fmt.Sprintf("Pattern %s with path %s", tc.pattern, tc.path), func(t *testing.T) {
+			t.Parallel()
+			p, err := parsePattern(tc.pattern)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing pattern: %s", err)
+			}
+			if res := p.match(tc.path, tc.isDir); res != tc.match {
+				t.Fatalf("Expected match of %s against %s to be %t, found %t", tc.pattern, tc.path, tc.match, res)
+			}
+		})
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	if _, err := New(".", Options{Exclude: []string{"[a-"}}); err == nil {
+		t.Errorf("Expected invalid pattern to be rejected.")
+	}
+}
+
+func TestExcludeAndInclude(t *testing.T) {
+	f, err := New("root", Options{
+		Exclude: []string{"node_modules/", "*.tmp", "!keep.tmp"},
+		Include: []string{"*.go", "*.tmp"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error creating filter: %s", err)
+	}
+	testCases := []struct {
+		path string
+		dir  bool
+		skip bool
+	}{
+		{"root/src", true, false},
+		{"root/src/main.go", false, false},
+		{"root/src/README.md", false, true},
+		{"root/src/node_modules", true, true},
+		{"root/src/scratch.tmp", false, true},
+		{"root/src/keep.tmp", false, false},
+	}
+	for _, tc := range testCases {
+		info := fileInfoMock{dir: tc.dir, basename: filepath.Base(tc.path)}
+		if res := f.Skip(tc.path, info); res != tc.skip {
+			t.Errorf("Expected %s to be skipped: %t, found %t", tc.path, tc.skip, res)
+		}
+	}
+}
+
+func TestAbsolutePathsBelowRelativeRoot(t *testing.T) {
+	f, err := New(".", Options{Exclude: []string{"*.tmp"}})
+	if err != nil {
+		t.Fatalf("Unexpected error creating filter: %s", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, skip := range map[string]bool{"scratch.tmp": true, "main.go": false} {
+		path := filepath.Join(wd, "src", name)
+		if res := f.Skip(path, fileInfoMock{basename: name}); res != skip {
+			t.Errorf("Expected %s to be skipped: %t, found %t", path, skip, res)
+		}
+	}
+}
+
+func TestExcludeFrom(t *testing.T) {
+	file, err := ioutil.TempFile("", "forest-exclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString("# Comments are ignored\n\n*.iso\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	f, err := New(".", Options{ExcludeFrom: file.Name()})
+	if err != nil {
+		t.Fatalf("Unexpected error creating filter: %s", err)
+	}
+	if !f.Skip("images/disk.iso", fileInfoMock{basename: "disk.iso"}) {
+		t.Errorf("Expected pattern from file to exclude disk.iso")
+	}
+	if _, err := New(".", Options{ExcludeFrom: "/path/that/does/not/exist"}); err == nil {
+		t.Errorf("Expected a missing exclude file to be reported")
+	}
+}
+
+func TestRespectGitignore(t *testing.T) {
+	root, err := ioutil.TempDir("", "forest-gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		".gitignore":         "*.log\nbuild/\n",
+		"app/.gitignore":     "!important.log\n/local\n",
+		"app/lib/.gitignore": "*.gen.go\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := New(root, Options{RespectGitignore: true})
+	if err != nil {
+		t.Fatalf("Unexpected error creating filter: %s", err)
+	}
+	testCases := []struct {
+		path string
+		dir  bool
+		skip bool
+	}{
+		{".git", true, true},
+		{"debug.log", false, true},
+		{"build", true, true},
+		{"app/build", true, true},
+		{"app/debug.log", false, true},
+		{"app/important.log", false, false},
+		{"app/local", true, true},
+		{"app/lib/local", true, false},
+		{"app/lib/types.gen.go", false, true},
+		{"types.gen.go", false, false},
+		{"app/lib/main.go", false, false},
+	}
+	for _, tc := range testCases {
+		info := fileInfoMock{dir: tc.dir, basename: filepath.Base(tc.path)}
+		if res := f.Skip(filepath.Join(root, tc.path), info); res != tc.skip {
+			t.Errorf("Expected %s to be skipped: %t, found %t", tc.path, tc.skip, res)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A single glob pattern, following the same rules as .gitignore files:
+//   - A pattern without a slash matches a name at any depth, such as "*.log".
+//   - A pattern with a slash is relative to the directory it applies to, such as "build/*.o".
+//   - A "**" segment matches any number of directories, such as "src/**/test".
+//   - A trailing slash only matches directories, such as "vendor/".
+//   - A leading "!" negates the pattern, so that it re-includes what an earlier one left out.
+type pattern struct {
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+func parsePattern(p string) (pattern, error) {
+	var pat pattern
+	if strings.HasPrefix(p, "!") {
+		pat.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		pat.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return pat, errors.New("Empty pattern")
+	}
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	pat.segments = strings.Split(p, "/")
+	if !anchored {
+		pat.segments = append([]string{"**"}, pat.segments...)
+	}
+	for _, s := range pat.segments {
+		if _, err := filepath.Match(s, ""); err != nil {
+			return pat, errors.New(fmt.Sprintf("Invalid pattern \"%s\": %s", p, err))
+		}
+	}
+	return pat, nil
+}
+
+// match reports whether the slash-separated path, relative to where the pattern applies, matches.
+func (p pattern) match(path string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(path, "/"))
+}
+
+func matchSegments(pattern []string, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest of the pattern against every possible suffix of the path.
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// Finds the last pattern that matches the path, since later patterns override earlier ones.
+func matchLast(patterns []pattern, path string, isDir bool) (matched bool, negated bool) {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i].match(path, isDir) {
+			return true, patterns[i].negate
+		}
+	}
+	return false, false
+}
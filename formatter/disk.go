@@ -10,6 +10,7 @@ const (
 	KB = 1024 * B
 	MB = 1024 * KB
 	GB = 1024 * MB
+	TB = 1024 * GB
 )
 
 func HumaniseStorage(bytes int64) string {
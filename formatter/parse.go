@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseStorage parses a number of bytes with an optional unit, such as 512, 100K, 1.5MB or 2G. Units
+// are powers of 1024, the same as HumaniseStorage uses.
+func ParseStorage(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		bytes  int64
+	}{
+		{"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB},
+		{"T", TB}, {"G", GB}, {"M", MB}, {"K", KB}, {"B", B},
+	}
+	number := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(B)
+	for _, u := range units {
+		if strings.HasSuffix(number, u.suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(number, u.suffix))
+			multiplier = u.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, errors.New(fmt.Sprintf("Invalid size \"%s\" (such as 512, 100K, 1.5M or 2G)", s))
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// ParseAge parses a length of time with a unit, such as 36h, 180d, 2w or 1y. Days are 24 hours,
+// and years 365 days.
+func ParseAge(s string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		length time.Duration
+	}{
+		{"h", time.Hour}, {"d", 24 * time.Hour}, {"w", 7 * 24 * time.Hour}, {"y", 365 * 24 * time.Hour},
+	}
+	number := strings.ToLower(strings.TrimSpace(s))
+	for _, u := range units {
+		if !strings.HasSuffix(number, u.suffix) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), 64)
+		if err != nil || value < 0 {
+			break
+		}
+		return time.Duration(value * float64(u.length)), nil
+	}
+	return 0, errors.New(fmt.Sprintf("Invalid age \"%s\" (such as 36h, 180d, 2w or 1y)", s))
+}
@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStorage(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"100K", 100 * KB},
+		{"100kb", 100 * KB},
+		{"1.5M", 3 * MB / 2},
+		{"2 GB", 2 * GB},
+		{"1T", TB},
+	}
+	for _, tc := range testCases {
+		res, err := ParseStorage(tc.in)
+		if err != nil || res != tc.out {
+			t.Errorf("Expected %s to be parsed as %d bytes, found %d (%v)", tc.in, tc.out, res, err)
+		}
+	}
+	for _, in := range []string{"", "M", "lots", "-1K"} {
+		if _, err := ParseStorage(in); err == nil {
+			t.Errorf("Expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	testCases := []struct {
+		in  string
+		out time.Duration
+	}{
+		{"36h", 36 * time.Hour},
+		{"180d", 180 * day},
+		{"1.5D", 36 * time.Hour},
+		{"2w", 14 * day},
+		{"1y", 365 * day},
+	}
+	for _, tc := range testCases {
+		res, err := ParseAge(tc.in)
+		if err != nil || res != tc.out {
+			t.Errorf("Expected %s to be parsed as %s, found %s (%v)", tc.in, tc.out, res, err)
+		}
+	}
+	for _, in := range []string{"", "180", "d", "6m", "-1d"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("Expected %q to be rejected", in)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package formatter
+
+import "time"
+
+// FormatTime formats a modification or access time, to the minute, in the local time zone.
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
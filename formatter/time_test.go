@@ -0,0 +1,16 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	if res := FormatTime(time.Time{}); res != "-" {
+		t.Fatalf("Expected unknown time to be formatted as -, found %s", res)
+	}
+	in := time.Date(2019, time.March, 4, 15, 6, 59, 0, time.Local)
+	if res := FormatTime(in); res != "2019-03-04 15:06" {
+		t.Fatalf("Expected time to be formatted as 2019-03-04 15:06, found %s", res)
+	}
+}
@@ -0,0 +1,157 @@
+// Package mount finds out where filesystems are mounted, so that walks can stay on one filesystem
+// and report the mount points they came across.
+package mount
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/robinmitra/forest/walker"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mount is a filesystem mounted somewhere.
+type Mount struct {
+	Point  string
+	Source string
+	FSType string
+}
+
+// Table holds the filesystems currently mounted, by the absolute path they're mounted at.
+type Table map[string]Mount
+
+// parseMountInfo reads a table in the format of /proc/self/mountinfo, where each line looks like:
+//
+//	36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// with a variable number of optional fields before the dash. When several filesystems are mounted
+// at the same point, the last one hides the others, and so wins.
+func parseMountInfo(r io.Reader) (Table, error) {
+	table := Table{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		dash := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				dash = i
+				break
+			}
+		}
+		if len(fields) < 5 || dash < 0 || dash+2 >= len(fields) {
+			return nil, errors.New(fmt.Sprintf("Malformed mount information \"%s\"", scanner.Text()))
+		}
+		point := unescape(fields[4])
+		table[point] = Mount{Point: point, Source: unescape(fields[dash+2]), FSType: fields[dash+1]}
+	}
+	return table, scanner.Err()
+}
+
+// unescape decodes the octal escapes, such as \040 for a space, which mount information uses for
+// whitespace and backslashes.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Crossing is a mount point which a walk came across, and either went into or skipped.
+type Crossing struct {
+	Mount
+	// Path of the mount point, as it was walked.
+	Path    string
+	Skipped bool
+}
+
+// Guard decides which mount points a walk goes into, and keeps track of the ones it came across.
+// It's safe for concurrent use, as Skip is called concurrently by walker.Walk.
+type Guard struct {
+	mounts Table
+	// Device of the root being walked, and where relative paths are relative to.
+	rootDev uint64
+	cwd     string
+	// Whether to stay on the filesystem of the root, and the types of filesystems to leave out.
+	oneFileSystem bool
+	skipTypes     map[string]bool
+	mutex         sync.Mutex
+	crossings     []Crossing
+}
+
+// NewGuard returns a guard for walking below root, given the filesystems currently mounted.
+func NewGuard(root string, mounts Table, oneFileSystem bool, skipTypes []string) (*Guard, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	g := &Guard{
+		mounts:        mounts,
+		cwd:           cwd,
+		oneFileSystem: oneFileSystem,
+		skipTypes:     make(map[string]bool),
+	}
+	if stat, ok := walker.StatOf(info); ok {
+		g.rootDev = stat.Dev
+	}
+	for _, t := range skipTypes {
+		g.skipTypes[t] = true
+	}
+	return g, nil
+}
+
+// Skip reports whether the directory at path is on another filesystem which isn't to be walked,
+// and keeps track of it if it's a mount point. It can be used as walker.Options.Skip.
+func (g *Guard) Skip(path string, info os.FileInfo) bool {
+	if !info.IsDir() {
+		return false
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(g.cwd, path)
+	}
+	m, isMount := g.mounts[abs]
+	stat, ok := walker.StatOf(info)
+	otherDevice := ok && stat.Dev != g.rootDev
+	if !isMount && !otherDevice {
+		return false
+	}
+	skip := g.oneFileSystem && otherDevice || isMount && g.skipTypes[m.FSType]
+	// Without -x, everything inside another filesystem is on another device, but only the mount
+	// point itself is worth reporting.
+	if isMount || skip {
+		g.mutex.Lock()
+		g.crossings = append(g.crossings, Crossing{Mount: m, Path: path, Skipped: skip})
+		g.mutex.Unlock()
+	}
+	return skip
+}
+
+// Crossings returns the mount points the walk came across, by path.
+func (g *Guard) Crossings() []Crossing {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	crossings := append([]Crossing(nil), g.crossings...)
+	sort.Slice(crossings, func(i, j int) bool { return crossings[i].Path < crossings[j].Path })
+	return crossings
+}
@@ -0,0 +1,84 @@
+package mount
+
+import (
+	"github.com/robinmitra/forest/walker"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:5 / /proc rw,nosuid shared:2 - proc proc rw
+24 22 0:40 / /mnt/my\040disk rw,relatime shared:3 master:1 - fuse.sshfs host:/srv rw
+25 22 0:41 / /proc rw - tmpfs tmpfs rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	table, err := parseMountInfo(strings.NewReader(mountInfo))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing mount information: %s", err)
+	}
+	expected := Table{
+		"/":            {Point: "/", Source: "/dev/sda1", FSType: "ext4"},
+		"/proc":        {Point: "/proc", Source: "tmpfs", FSType: "tmpfs"},
+		"/mnt/my disk": {Point: "/mnt/my disk", Source: "host:/srv", FSType: "fuse.sshfs"},
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("Expected %v, found %v", expected, table)
+	}
+
+	if _, err := parseMountInfo(strings.NewReader("22 1 8:1 / / rw ext4 /dev/sda1\n")); err == nil {
+		t.Error("Expected an error for a line without a separator")
+	}
+}
+
+type dirMock struct {
+	name string
+	dev  uint64
+}
+
+func (d dirMock) Name() string       { return d.name }
+func (d dirMock) Size() int64        { return 0 }
+func (d dirMock) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d dirMock) ModTime() time.Time { return time.Time{} }
+func (d dirMock) IsDir() bool        { return true }
+func (d dirMock) Sys() interface{}   { return &walker.Stat{Dev: d.dev} }
+
+func TestGuard(t *testing.T) {
+	table := Table{
+		"/data/nfs":  {Point: "/data/nfs", Source: "server:/export", FSType: "nfs"},
+		"/data/bind": {Point: "/data/bind", Source: "/dev/sda1", FSType: "ext4"},
+		"/data/usb":  {Point: "/data/usb", Source: "/dev/sdb1", FSType: "vfat"},
+	}
+	walk := func(g *Guard) []bool {
+		return []bool{
+			g.Skip("/data/plain", dirMock{name: "plain", dev: 1}),
+			g.Skip("/data/bind", dirMock{name: "bind", dev: 1}),
+			g.Skip("/data/nfs", dirMock{name: "nfs", dev: 2}),
+			g.Skip("/data/usb", dirMock{name: "usb", dev: 3}),
+			g.Skip("/data/usb/inside", dirMock{name: "inside", dev: 3}),
+		}
+	}
+
+	g := &Guard{mounts: table, rootDev: 1, cwd: "/", skipTypes: map[string]bool{"nfs": true}}
+	if skipped := walk(g); !reflect.DeepEqual(skipped, []bool{false, false, true, false, false}) {
+		t.Errorf("Expected only the nfs mount to be skipped, found %v", skipped)
+	}
+	var paths []string
+	for _, c := range g.Crossings() {
+		paths = append(paths, c.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"/data/bind", "/data/nfs", "/data/usb"}) {
+		t.Errorf("Expected every mount point to be reported once, found %v", paths)
+	}
+
+	g = &Guard{mounts: table, rootDev: 1, cwd: "/", oneFileSystem: true, skipTypes: map[string]bool{}}
+	if skipped := walk(g); !reflect.DeepEqual(skipped, []bool{false, false, true, true, true}) {
+		t.Errorf("Expected other filesystems to be skipped, found %v", skipped)
+	}
+	if crossings := g.Crossings(); len(crossings) != 4 || crossings[0].Skipped || !crossings[1].Skipped {
+		t.Errorf("Expected the bind mount to be crossed and the others skipped, found %+v", crossings)
+	}
+}
@@ -0,0 +1,13 @@
+package mount
+
+import "os"
+
+// Load returns the filesystems currently mounted, as seen by this process.
+func Load() (Table, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
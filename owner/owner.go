@@ -0,0 +1,85 @@
+// Package owner looks up the names of the users and groups which own files.
+package owner
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// Names looks up user and group names by their IDs, and remembers them, since a tree of files is
+// usually owned by only a handful of users. IDs without a name are shown as numbers instead. It's
+// safe for concurrent use.
+type Names struct {
+	mutex  sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+	// Overridden by tests, so that they don't depend on the users of the machine.
+	lookupUser  func(id string) (string, error)
+	lookupGroup func(id string) (string, error)
+}
+
+func NewNames() *Names {
+	return &Names{
+		users:  make(map[uint32]string),
+		groups: make(map[uint32]string),
+		lookupUser: func(id string) (string, error) {
+			u, err := user.LookupId(id)
+			if err != nil {
+				return "", err
+			}
+			return u.Username, nil
+		},
+		lookupGroup: func(id string) (string, error) {
+			g, err := user.LookupGroupId(id)
+			if err != nil {
+				return "", err
+			}
+			return g.Name, nil
+		},
+	}
+}
+
+// User returns the name of the user with the given ID.
+func (n *Names) User(uid uint32) string {
+	return n.lookup(n.users, n.lookupUser, uid)
+}
+
+// Group returns the name of the group with the given ID.
+func (n *Names) Group(gid uint32) string {
+	return n.lookup(n.groups, n.lookupGroup, gid)
+}
+
+func (n *Names) lookup(cache map[uint32]string, lookup func(string) (string, error), id uint32) string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if name, ok := cache[id]; ok {
+		return name
+	}
+	name, err := lookup(strconv.FormatUint(uint64(id), 10))
+	if err != nil || name == "" {
+		name = strconv.FormatUint(uint64(id), 10)
+	}
+	cache[id] = name
+	return name
+}
+
+// LookupUser returns the ID of the user with the given name. A number is taken as an ID as it is,
+// so that users without a name on this machine, such as in a saved scan, can be picked too.
+func LookupUser(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Unknown user \"%s\"", name))
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		// Users on Windows have security identifiers rather than numeric IDs.
+		return 0, errors.New(fmt.Sprintf("User \"%s\" has no numeric ID", name))
+	}
+	return uint32(id), nil
+}
@@ -0,0 +1,51 @@
+package owner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamesAreLookedUpOnce(t *testing.T) {
+	n := NewNames()
+	lookups := 0
+	n.lookupUser = func(id string) (string, error) {
+		lookups++
+		if id == "1000" {
+			return "alice", nil
+		}
+		return "", errors.New("unknown user")
+	}
+	for i := 0; i < 3; i++ {
+		if name := n.User(1000); name != "alice" {
+			t.Errorf("Expected user 1000 to be alice, found %s", name)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Expected user to be looked up once, found %d lookups", lookups)
+	}
+	if name := n.User(4242); name != "4242" {
+		t.Errorf("Expected unknown user to be shown by ID, found %s", name)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	n := NewNames()
+	n.lookupGroup = func(id string) (string, error) {
+		return "staff", nil
+	}
+	if name := n.Group(20); name != "staff" {
+		t.Errorf("Expected group 20 to be staff, found %s", name)
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	if id, err := LookupUser("1234"); err != nil || id != 1234 {
+		t.Errorf("Expected a number to be taken as an ID, found %d (%v)", id, err)
+	}
+	if id, err := LookupUser("root"); err != nil || id != 0 {
+		t.Skipf("There is no root user to look up: %v", err)
+	}
+	if _, err := LookupUser("no-such-user-here"); err == nil {
+		t.Error("Expected an unknown user to be rejected")
+	}
+}
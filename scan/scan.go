@@ -0,0 +1,290 @@
+package scan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/robinmitra/forest/walker"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A saved scan starts with the magic bytes and the version of the format, followed by a gzipped
+// stream of gob values: the header, and then one record per file or directory in walk order.
+var magic = []byte("FORESTSCAN")
+
+// Version of the format. Bump it whenever the header or records change in an incompatible way.
+const version uint16 = 1
+
+type header struct {
+	Root    string
+	Created time.Time
+}
+
+// A single file or directory, as it was found during the scan.
+type record struct {
+	// Slash-separated path relative to the root, which is empty for the root itself.
+	Path    string
+	Mode    uint32
+	Size    int64
+	ModTime int64 // Nanoseconds since the Unix epoch.
+	Stat    walker.Stat
+	HasStat bool
+	// Where a symbolic link points, which is empty for anything else.
+	Link string
+	// The reason the path couldn't be read, if it was skipped.
+	Err     string
+	ErrKind walker.ErrorKind
+}
+
+// Writer saves a scan to a file.
+type Writer struct {
+	root    string
+	file    *os.File
+	buffer  *bufio.Writer
+	gzip    *gzip.Writer
+	encoder *gob.Encoder
+	count   int
+}
+
+// Create creates the file at path, to save a scan of the tree rooted at root into.
+func Create(path string, root string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	root = filepath.Clean(root)
+	w := &Writer{root: root, file: file, buffer: bufio.NewWriter(file)}
+	if _, err := w.buffer.Write(magic); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := binary.Write(w.buffer, binary.BigEndian, version); err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.gzip = gzip.NewWriter(w.buffer)
+	w.encoder = gob.NewEncoder(w.gzip)
+	if err := w.encoder.Encode(header{Root: root, Created: time.Now()}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Add saves a file or directory, or the reason it couldn't be read. It has the same signature as
+// filepath.WalkFunc, so that it can be called from one.
+func (w *Writer) Add(path string, info os.FileInfo, err error) error {
+	rel, relErr := filepath.Rel(w.root, path)
+	if relErr != nil {
+		return relErr
+	}
+	r := record{Path: filepath.ToSlash(rel)}
+	if r.Path == "." {
+		r.Path = ""
+	}
+	if err != nil {
+		r.Err = err.Error()
+		r.ErrKind = walker.KindOf(err)
+	} else {
+		r.Mode = uint32(info.Mode())
+		r.Size = info.Size()
+		r.ModTime = info.ModTime().UnixNano()
+		r.Stat, r.HasStat = walker.StatOf(info)
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Links which can't be read are saved without a target.
+			r.Link, _ = os.Readlink(path)
+		}
+	}
+	w.count++
+	return w.encoder.Encode(r)
+}
+
+// Count returns the number of files and directories saved so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Close flushes everything to the file, and closes it.
+func (w *Writer) Close() error {
+	if err := w.gzip.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Reader replays a saved scan.
+type Reader struct {
+	header  header
+	file    *os.File
+	gzip    *gzip.Reader
+	decoder *gob.Decoder
+}
+
+// Open opens a saved scan, and checks that it can be read.
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: file}
+	if err := r.readHeader(path); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) readHeader(path string) error {
+	buffer := bufio.NewReader(r.file)
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(buffer, m); err != nil || string(m) != string(magic) {
+		return errors.New(fmt.Sprintf("File \"%s\" is not a saved scan", path))
+	}
+	var v uint16
+	if err := binary.Read(buffer, binary.BigEndian, &v); err != nil {
+		return err
+	}
+	if v > version {
+		return errors.New(fmt.Sprintf(
+			"Saved scan \"%s\" has version %d, but only up to version %d is supported",
+			path,
+			v,
+			version,
+		))
+	}
+	gz, err := gzip.NewReader(buffer)
+	if err != nil {
+		return err
+	}
+	r.gzip = gz
+	r.decoder = gob.NewDecoder(gz)
+	return r.decoder.Decode(&r.header)
+}
+
+// Root returns the path of the tree that was scanned.
+func (r *Reader) Root() string {
+	return r.header.Root
+}
+
+// Created returns when the scan was made.
+func (r *Reader) Created() time.Time {
+	return r.header.Created
+}
+
+// Walk calls fn for each file or directory in the saved scan, in the same order and with the same
+// paths as walker.Walk did when the scan was made. Just like walker.Walk, it honours the dot-file
+// and skip options, and fn may return filepath.SkipDir to skip a directory. A scan can only be
+// walked once.
+func (r *Reader) Walk(opts walker.Options, fn filepath.WalkFunc) error {
+	// Records are in walk order, so skipping a directory means skipping records until the next one
+	// outside of it.
+	skipping := ""
+	for {
+		var rec record
+		if err := r.decoder.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if skipping != "" && strings.HasPrefix(rec.Path, skipping+"/") {
+			continue
+		}
+		skipping = ""
+
+		path := filepath.Join(r.header.Root, filepath.FromSlash(rec.Path))
+		if rec.Err != "" {
+			err := fn(path, nil, replayedError{msg: rec.Err, kind: rec.ErrKind})
+			if err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		info := fileInfo{name: filepath.Base(path), rec: rec}
+		if rec.Path != "" && r.skip(path, info, opts) {
+			if info.IsDir() {
+				skipping = rec.Path
+			}
+			continue
+		}
+		err := fn(path, info, nil)
+		if err == filepath.SkipDir {
+			skipping = rec.Path
+			if !info.IsDir() {
+				// Skip the rest of the directory the file is in, the same as filepath.Walk.
+				skipping = parent(rec.Path)
+			}
+			if skipping == "" {
+				return nil
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Reader) skip(path string, info os.FileInfo, opts walker.Options) bool {
+	if !opts.IncludeDotFiles && strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+	return opts.Skip != nil && opts.Skip(path, info)
+}
+
+// Close closes the file.
+func (r *Reader) Close() error {
+	r.gzip.Close()
+	return r.file.Close()
+}
+
+func parent(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// Describes a file or directory from a saved scan, the same way as os.Lstat would have.
+type fileInfo struct {
+	name string
+	rec  record
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.rec.Size }
+func (f fileInfo) Mode() os.FileMode  { return os.FileMode(f.rec.Mode) }
+func (f fileInfo) ModTime() time.Time { return time.Unix(0, f.rec.ModTime) }
+func (f fileInfo) IsDir() bool        { return f.Mode().IsDir() }
+func (f fileInfo) Sys() interface{} {
+	if !f.rec.HasStat {
+		return nil
+	}
+	stat := f.rec.Stat
+	return &stat
+}
+
+// LinkTarget returns where a symbolic link pointed when the scan was made, and whether info
+// describes a file from a saved scan at all.
+func LinkTarget(info os.FileInfo) (string, bool) {
+	f, ok := info.(fileInfo)
+	return f.rec.Link, ok
+}
+
+// An error replayed from a saved scan, which remembers the kind of error it originally was.
+type replayedError struct {
+	msg  string
+	kind walker.ErrorKind
+}
+
+func (e replayedError) Error() string          { return e.msg }
+func (e replayedError) Kind() walker.ErrorKind { return e.kind }
@@ -0,0 +1,235 @@
+package scan
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/robinmitra/forest/walker"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createTree(t *testing.T) string {
+	root, err := ioutil.TempDir("", "forest-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"a/b/file1.txt", "a/file2.bin", "a/.hidden/file3.txt", "c/file4.txt", "file5.txt"}
+	for i, p := range paths {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, 100*i), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// Describes everything the walk function gets, so that live and replayed walks can be compared.
+func describe(path string, info os.FileInfo, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s error %s", path, err)
+	}
+	return fmt.Sprintf(
+		"%s name=%s dir=%t size=%d usage=%d mode=%s time=%d",
+		path,
+		info.Name(),
+		info.IsDir(),
+		info.Size(),
+		walker.DiskUsage(info),
+		info.Mode(),
+		info.ModTime().UnixNano(),
+	)
+}
+
+func save(t *testing.T, root string, opts walker.Options) string {
+	file, err := ioutil.TempFile("", "forest-scan-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	w, err := Create(file.Name(), root)
+	if err != nil {
+		t.Fatalf("Unexpected error creating scan: %s", err)
+	}
+	if err := walker.Walk(root, opts, w.Add); err != nil {
+		t.Fatalf("Unexpected error saving scan: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error closing scan: %s", err)
+	}
+	return file.Name()
+}
+
+func TestReplayMatchesLiveWalk(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	opts := walker.Options{Jobs: 2, IncludeDotFiles: true}
+	path := save(t, root, opts)
+	defer os.Remove(path)
+
+	var expected []string
+	err := walker.Walk(root, opts, func(path string, info os.FileInfo, err error) error {
+		expected = append(expected, describe(path, info, err))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error opening scan: %s", err)
+	}
+	defer r.Close()
+	if r.Root() != root {
+		t.Errorf("Expected root to be %s, found %s", root, r.Root())
+	}
+	var replayed []string
+	err = r.Walk(opts, func(path string, info os.FileInfo, err error) error {
+		replayed = append(replayed, describe(path, info, err))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replaying scan: %s", err)
+	}
+	if !reflect.DeepEqual(replayed, expected) {
+		t.Errorf("Expected replay to match live walk.\nExpected: %v\nFound: %v", expected, replayed)
+	}
+}
+
+func TestReplaySkipsDirectoriesAndDotFiles(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	path := save(t, root, walker.Options{Jobs: 2, IncludeDotFiles: true})
+	defer os.Remove(path)
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error opening scan: %s", err)
+	}
+	defer r.Close()
+	var names []string
+	err = r.Walk(walker.Options{}, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() && info.Name() == "b" {
+			return filepath.SkipDir
+		}
+		names = append(names, info.Name())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replaying scan: %s", err)
+	}
+	expected := []string{filepath.Base(root), "a", "file2.bin", "c", "file4.txt", "file5.txt"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v to be replayed, found %v", expected, names)
+	}
+}
+
+func TestReplayedErrorsKeepTheirKind(t *testing.T) {
+	file, err := ioutil.TempFile("", "forest-scan-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	w, err := Create(file.Name(), "/root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("/root/secret", nil, os.ErrPermission); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	skipped := walker.Skipped{}
+	err = r.Walk(walker.Options{}, func(path string, info os.FileInfo, err error) error {
+		skipped.Add(path, err)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped.Paths) != 1 || skipped.Paths[0].Kind != walker.PermissionDenied {
+		t.Errorf("Expected permission denied error to be replayed, found %v", skipped.Paths)
+	}
+}
+
+func TestOpenRejectsOtherFiles(t *testing.T) {
+	file, err := ioutil.TempFile("", "forest-scan-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("definitely not a scan")
+	file.Close()
+	if _, err := Open(file.Name()); err == nil {
+		t.Errorf("Expected a file which isn't a scan to be rejected")
+	}
+
+	newer, err := ioutil.TempFile("", "forest-scan-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(newer.Name())
+	newer.Write(magic)
+	binary.Write(newer, binary.BigEndian, version+1)
+	newer.Close()
+	if _, err := Open(newer.Name()); err == nil {
+		t.Errorf("Expected a scan with a newer version to be rejected")
+	}
+	if _, err := Open("/path/that/does/not/exist"); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing scan to be reported, found %v", err)
+	}
+}
+
+func TestReplayedLinksKeepTheirTarget(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	link := filepath.Join(root, "link")
+	if err := os.Symlink("file5.txt", link); err != nil {
+		t.Skipf("Symbolic links aren't supported: %s", err)
+	}
+	path := save(t, root, walker.Options{Jobs: 2})
+	defer os.Remove(path)
+	// The link now points elsewhere, but the scan describes it as it was.
+	os.Remove(link)
+	os.Symlink("c", link)
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error opening scan: %s", err)
+	}
+	defer r.Close()
+	targets := make(map[string]string)
+	err = r.Walk(walker.Options{}, func(path string, info os.FileInfo, err error) error {
+		target, ok := LinkTarget(info)
+		if !ok {
+			t.Errorf("Expected %s to come from the scan", path)
+		}
+		if target != "" {
+			targets[info.Name()] = target
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replaying scan: %s", err)
+	}
+	if expected := map[string]string{"link": "file5.txt"}; !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Expected link targets %v, found %v", expected, targets)
+	}
+	if _, ok := LinkTarget(nil); ok {
+		t.Errorf("Expected a file which isn't from a scan not to have a saved target")
+	}
+}
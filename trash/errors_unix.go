@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package trash
+
+import "syscall"
+
+func isCrossDevice(err error) bool {
+	return err == syscall.EXDEV
+}
@@ -0,0 +1,116 @@
+// Package trash moves files to the trash, as described by the freedesktop.org trash specification,
+// so that file managers can list and restore them.
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Trash is a trash directory, with the trashed files in its files directory, and a .trashinfo file
+// describing each of them in its info directory.
+type Trash struct {
+	dir string
+	now func() time.Time
+}
+
+// Home returns the trash of the current user, which is in $XDG_DATA_HOME/Trash, or
+// ~/.local/share/Trash when XDG_DATA_HOME isn't set.
+func Home() (*Trash, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return New(filepath.Join(data, "Trash")), nil
+}
+
+// New returns the trash in the given directory.
+func New(dir string) *Trash {
+	return &Trash{dir: dir, now: time.Now}
+}
+
+// Dir returns the directory of the trash.
+func (t *Trash) Dir() string {
+	return t.dir
+}
+
+// Put moves a file or directory to the trash. Files can only be moved to a trash on the same
+// filesystem, so trashing files on other filesystems fails, and they're left where they are.
+func (t *Trash) Put(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return err
+	}
+	files := filepath.Join(t.dir, "files")
+	infos := filepath.Join(t.dir, "info")
+	for _, dir := range []string{files, infos} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	info, name, err := t.createInfo(infos, filepath.Base(abs))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(
+		info,
+		"[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(),
+		t.now().Format("2006-01-02T15:04:05"),
+	)
+	if closeErr := info.Close(); err == nil {
+		err = closeErr
+	}
+	infoPath := filepath.Join(infos, name+".trashinfo")
+	if err != nil {
+		os.Remove(infoPath)
+		return err
+	}
+	if err := os.Rename(abs, filepath.Join(files, name)); err != nil {
+		os.Remove(infoPath)
+		if linkErr, ok := err.(*os.LinkError); ok && isCrossDevice(linkErr.Err) {
+			return errors.New(fmt.Sprintf("Can't move \"%s\" to a trash on another filesystem", path))
+		}
+		return err
+	}
+	return nil
+}
+
+// createInfo creates the .trashinfo file for a file with the given name. If the trash already has a
+// file with that name, a number is added to the name to make it unique. The info file is created
+// exclusively, so that it claims the name even if something else is trashing files at the same time.
+func (t *Trash) createInfo(infos string, base string) (*os.File, string, error) {
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = base + "." + strconv.Itoa(i)
+		}
+		path := filepath.Join(infos, name+".trashinfo")
+		info, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := os.Lstat(filepath.Join(t.dir, "files", name)); err == nil {
+			// A file was left behind without its info, so don't overwrite it.
+			info.Close()
+			os.Remove(path)
+			continue
+		}
+		return info, name, nil
+	}
+}
@@ -0,0 +1,69 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutMovesFilesToTheTrash(t *testing.T) {
+	root, err := ioutil.TempDir("", "forest-trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	trash := New(filepath.Join(root, "Trash"))
+	trash.now = func() time.Time { return time.Date(2019, time.June, 1, 12, 30, 0, 0, time.Local) }
+
+	var paths []string
+	for _, dir := range []string{"a", "b c"} {
+		path := filepath.Join(root, dir, "data.bin")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(dir), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if err := trash.Put(path); err != nil {
+			t.Fatalf("Unexpected error trashing %s: %s", path, err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be moved to the trash", path)
+		}
+	}
+
+	// The second file has the same name, so it gets a number added to it.
+	content, err := ioutil.ReadFile(filepath.Join(root, "Trash", "files", "data.bin.2"))
+	if err != nil || string(content) != "b c" {
+		t.Errorf("Expected second file to be trashed as data.bin.2, found %q (%v)", content, err)
+	}
+	info, err := ioutil.ReadFile(filepath.Join(root, "Trash", "info", "data.bin.2.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[Trash Info]\nPath=" + filepath.Join(root, "b%20c", "data.bin") +
+		"\nDeletionDate=2019-06-01T12:30:00\n"
+	if string(info) != expected {
+		t.Errorf("Expected trash info to be:\n%s\nFound:\n%s", expected, info)
+	}
+}
+
+func TestPutLeavesMissingFilesAlone(t *testing.T) {
+	root, err := ioutil.TempDir("", "forest-trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	trash := New(filepath.Join(root, "Trash"))
+	if err := trash.Put(filepath.Join(root, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected missing file to be reported, found %v", err)
+	}
+	if infos, _ := ioutil.ReadDir(filepath.Join(root, "Trash", "info")); len(infos) != 0 {
+		t.Errorf("Expected no trash info to be left behind, found %d files", len(infos))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build dragonfly || linux || openbsd
+// +build dragonfly linux openbsd
+
+package walker
+
+import (
+	"syscall"
+	"time"
+)
+
+func atime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+}
@@ -0,0 +1,70 @@
+package walker
+
+import "os"
+
+// ExitCodePartial is the exit code of commands whose results are partial, because some paths
+// couldn't be read.
+const ExitCodePartial = 3
+
+// ErrorKind categorises why a path couldn't be read.
+type ErrorKind int
+
+const (
+	PermissionDenied ErrorKind = iota
+	Vanished
+	IOError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case PermissionDenied:
+		return "permission denied"
+	case Vanished:
+		return "vanished during scan"
+	}
+	return "I/O error"
+}
+
+// SkippedPath is a path which couldn't be read, and was left out of the results.
+type SkippedPath struct {
+	Path string
+	Kind ErrorKind
+	Err  error
+}
+
+// Skipped collects the paths that couldn't be read during a walk, so that the walk can carry on
+// past them. Like Links, it's not safe for concurrent use.
+type Skipped struct {
+	Paths []SkippedPath
+}
+
+// Add records that the path couldn't be read, because of err.
+func (s *Skipped) Add(path string, err error) {
+	s.Paths = append(s.Paths, SkippedPath{Path: path, Kind: KindOf(err), Err: err})
+}
+
+// Count returns how many paths were skipped because of the given kind of error.
+func (s *Skipped) Count(kind ErrorKind) int {
+	count := 0
+	for _, p := range s.Paths {
+		if p.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// KindOf categorises err. Errors which already know their kind, such as the ones replayed from saved
+// scans, can say so with a Kind method.
+func KindOf(err error) ErrorKind {
+	if e, ok := err.(interface{ Kind() ErrorKind }); ok {
+		return e.Kind()
+	}
+	if os.IsPermission(err) {
+		return PermissionDenied
+	}
+	if os.IsNotExist(err) {
+		return Vanished
+	}
+	return IOError
+}
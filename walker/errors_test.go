@@ -0,0 +1,25 @@
+package walker
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSkippedPathsAreCategorised(t *testing.T) {
+	s := Skipped{}
+	s.Add("a", &os.PathError{Op: "open", Path: "a", Err: syscall.EACCES})
+	s.Add("b", &os.PathError{Op: "lstat", Path: "b", Err: syscall.ENOENT})
+	s.Add("c", &os.PathError{Op: "readdirent", Path: "c", Err: syscall.EIO})
+	s.Add("d", &os.PathError{Op: "open", Path: "d", Err: syscall.EPERM})
+
+	expected := []ErrorKind{PermissionDenied, Vanished, IOError, PermissionDenied}
+	for i, kind := range expected {
+		if s.Paths[i].Kind != kind {
+			t.Errorf("Expected %s to be categorised as %s, found %s", s.Paths[i].Path, kind, s.Paths[i].Kind)
+		}
+	}
+	if s.Count(PermissionDenied) != 2 || s.Count(Vanished) != 1 || s.Count(IOError) != 1 {
+		t.Errorf("Unexpected counts of skipped paths")
+	}
+}
@@ -0,0 +1,48 @@
+package walker
+
+import "os"
+
+// FileID identifies a file by its device and inode, whichever path it is reached through.
+type FileID struct {
+	Dev uint64
+	Ino uint64
+}
+
+// Links keeps track of the hard-linked files seen during a walk, so that their size can be counted
+// only once. It's not safe for concurrent use, but walk functions are never called concurrently.
+type Links struct {
+	seen map[FileID]struct{}
+	// Whether every file is tracked, rather than only those with several hard links.
+	all bool
+}
+
+func NewLinks() *Links {
+	return &Links{seen: make(map[FileID]struct{})}
+}
+
+// NewFollowedLinks returns Links for a walk which follows symbolic links. As with du -L, every file
+// is tracked then, whatever its link count, since it can be reached both directly and through
+// symbolic links to it.
+func NewFollowedLinks() *Links {
+	return &Links{seen: make(map[FileID]struct{}), all: true}
+}
+
+// Visit records the file described by info, and reports whether it has more than one hard link, and
+// whether it has been visited already, through another one of its links.
+func (l *Links) Visit(info os.FileInfo) (linked bool, seen bool) {
+	if info.IsDir() {
+		return false, false
+	}
+	id, nlink, ok := fileID(info)
+	if !ok {
+		return false, false
+	}
+	linked = nlink > 1
+	if !linked && !l.all {
+		return false, false
+	}
+	if _, seen = l.seen[id]; !seen {
+		l.seen[id] = struct{}{}
+	}
+	return linked, seen
+}
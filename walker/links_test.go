@@ -0,0 +1,80 @@
+package walker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinksCountsEachInodeOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forest-links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	original := filepath.Join(dir, "original")
+	if err := ioutil.WriteFile(original, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(original, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("Hard links are not supported: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "single"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := NewLinks()
+	expected := []struct {
+		name   string
+		linked bool
+		seen   bool
+	}{
+		{"link", true, false},
+		{"original", true, true},
+		{"single", false, false},
+	}
+	for _, e := range expected {
+		info, err := os.Lstat(filepath.Join(dir, e.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linked, seen := links.Visit(info); linked != e.linked || seen != e.seen {
+			t.Errorf(
+				"Expected %s to be linked: %t and seen: %t, found linked: %t and seen: %t",
+				e.name,
+				e.linked,
+				e.seen,
+				linked,
+				seen,
+			)
+		}
+	}
+}
+
+func TestFollowedLinksCountsEveryInodeOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forest-links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "single"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filepath.Join(dir, "single"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, seen := NewLinks().Visit(info); seen {
+		t.Error("Expected a file with a single link to be new")
+	}
+	links := NewFollowedLinks()
+	if linked, seen := links.Visit(info); linked || seen {
+		t.Errorf("Expected single to be new and not linked, found linked: %t and seen: %t", linked, seen)
+	}
+	// The same file, reached again through a symbolic link to it.
+	if linked, seen := links.Visit(info); linked || !seen {
+		t.Errorf("Expected single to be seen but not linked, found linked: %t and seen: %t", linked, seen)
+	}
+}
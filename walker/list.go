@@ -0,0 +1,114 @@
+package walker
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkList calls fn for each path listed in r, one per line or separated by sep, rather than for
+// everything below a root. It's meant for lists produced by other tools, such as `git ls-files -z`
+// or `find -print0`.
+//
+// Each path is statted once, without following symbolic links. The directories leading up to each
+// path are passed to fn too, once each and before anything inside them, so that totals can be
+// rolled up through them as they are for Walk. If fn returns filepath.SkipDir for a directory,
+// the paths listed inside it are left out. Hidden files and directories, and those matched by
+// opts.Skip, are left out in the same way as they are by Walk. Only one path is held in memory at
+// a time, besides the directories seen so far.
+func WalkList(r io.Reader, sep byte, opts Options, fn filepath.WalkFunc) error {
+	l := lister{opts: opts, fn: fn, dirs: make(map[string]bool)}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString(sep)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if path := strings.TrimSuffix(line, string(sep)); path != "" {
+			if err := l.visit(filepath.Clean(path)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+type lister struct {
+	opts Options
+	fn   filepath.WalkFunc
+	// Directories already passed to fn, and whether the paths inside them are to be included.
+	dirs map[string]bool
+}
+
+func (l *lister) visit(path string) error {
+	parent := filepath.Dir(path)
+	if parent != path && parent != "." {
+		included, err := l.visitDir(parent)
+		if err != nil || !included {
+			return err
+		}
+	}
+	if _, seen := l.dirs[path]; seen {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err == nil && l.opts.FollowSymlinks {
+		info = follow(path, info)
+	}
+	if err == nil && info.IsDir() {
+		_, err = l.visitDir(path)
+		return err
+	}
+	if l.leaveOut(path, info) {
+		return nil
+	}
+	if err := l.fn(path, info, err); err != nil && err != filepath.SkipDir {
+		return err
+	}
+	return nil
+}
+
+// visitDir passes a directory, and the directories above it, to fn unless that's been done
+// already, and reports whether the paths inside it are to be included.
+func (l *lister) visitDir(path string) (bool, error) {
+	if included, seen := l.dirs[path]; seen {
+		return included, nil
+	}
+	if parent := filepath.Dir(path); parent != path && parent != "." {
+		included, err := l.visitDir(parent)
+		if err != nil {
+			return false, err
+		}
+		if !included {
+			l.dirs[path] = false
+			return false, nil
+		}
+	}
+	info, err := os.Lstat(path)
+	if l.leaveOut(path, info) {
+		l.dirs[path] = false
+		return false, nil
+	}
+	// A directory which can't be statted is reported once, rather than once for each path in it.
+	included := err == nil
+	if err = l.fn(path, info, err); err == filepath.SkipDir {
+		included, err = false, nil
+	}
+	l.dirs[path] = included
+	return included, err
+}
+
+func (l *lister) leaveOut(path string, info os.FileInfo) bool {
+	name := filepath.Base(path)
+	if filepath.Dir(path) == path || name == ".." {
+		return false
+	}
+	if !l.opts.IncludeDotFiles && isDotFile(name) {
+		return true
+	}
+	return info != nil && l.opts.Skip != nil && l.opts.Skip(path, info)
+}
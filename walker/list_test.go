@@ -0,0 +1,81 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalkListVisitsDirectoriesOnce(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	list := []string{
+		filepath.Join(root, "a/b/c/file1.txt"),
+		filepath.Join(root, "a/b/file2.txt"),
+		filepath.Join(root, "a/.hidden/file4.txt"),
+		filepath.Join(root, "d/file5.txt"),
+	}
+
+	paths := collect(t, func(fn filepath.WalkFunc) error {
+		return WalkList(strings.NewReader(strings.Join(list, "\x00")+"\x00"), 0, Options{}, fn)
+	})
+
+	var rel []string
+	for _, p := range paths {
+		if r, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(r, "..") {
+			rel = append(rel, r)
+		}
+	}
+	expected := []string{".", "a", "a/b", "a/b/c", "a/b/c/file1.txt", "a/b/file2.txt", "d", "d/file5.txt"}
+	if !reflect.DeepEqual(rel, expected) {
+		t.Errorf("Expected %v, found %v", expected, rel)
+	}
+}
+
+func TestWalkListSkipDir(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	list := filepath.Join(root, "a/b/file2.txt") + "\n" + filepath.Join(root, "a/file3.txt") + "\n"
+
+	var files []string
+	err := WalkList(strings.NewReader(list), '\n', Options{}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "b" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files = append(files, filepath.Base(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking list: %s", err)
+	}
+	if !reflect.DeepEqual(files, []string{"file3.txt"}) {
+		t.Errorf("Expected only file3.txt, found %v", files)
+	}
+}
+
+func TestWalkListReportsMissingPaths(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	list := filepath.Join(root, "missing/file.txt") + "\n" + filepath.Join(root, "missing/other.txt")
+
+	var failed []string
+	err := WalkList(strings.NewReader(list), '\n', Options{}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			failed = append(failed, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking list: %s", err)
+	}
+	if !reflect.DeepEqual(failed, []string{filepath.Join(root, "missing")}) {
+		t.Errorf("Expected the missing directory to be reported once, found %v", failed)
+	}
+}
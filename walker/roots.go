@@ -0,0 +1,65 @@
+package walker
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Overlap is a root which was left out, because it's inside another one.
+type Overlap struct {
+	Path   string
+	Within string
+}
+
+// DistinctRoots leaves out the roots which are the same as, or inside, another one, so that
+// nothing is walked twice. Roots are compared by their absolute paths, after resolving symbolic
+// links, and the ones which are kept stay in the order they were given.
+func DistinctRoots(paths []string) ([]string, []Overlap) {
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		resolved[i] = resolve(p)
+	}
+	var roots []string
+	var overlaps []Overlap
+	for i, p := range paths {
+		within := -1
+		for j := range paths {
+			if j == i {
+				continue
+			}
+			// Of two identical roots, the first one is kept.
+			if resolved[i] == resolved[j] && j < i || isInside(resolved[i], resolved[j]) {
+				within = j
+				break
+			}
+		}
+		if within >= 0 {
+			overlaps = append(overlaps, Overlap{Path: p, Within: paths[within]})
+			continue
+		}
+		roots = append(roots, p)
+	}
+	return roots, overlaps
+}
+
+func resolve(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// isInside reports whether path is strictly below dir.
+func isInside(path string, dir string) bool {
+	if path == dir {
+		return false
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
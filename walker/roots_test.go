@@ -0,0 +1,38 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDistinctRoots(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "a", "b")
+	d := filepath.Join(root, "d")
+
+	roots, overlaps := DistinctRoots([]string{b, d, a, d + "/", filepath.Join(root, "wide")})
+
+	expected := []string{d, a, filepath.Join(root, "wide")}
+	if !reflect.DeepEqual(roots, expected) {
+		t.Errorf("Expected roots %v, found %v", expected, roots)
+	}
+	expectedOverlaps := []Overlap{{Path: b, Within: a}, {Path: d + "/", Within: d}}
+	if !reflect.DeepEqual(overlaps, expectedOverlaps) {
+		t.Errorf("Expected overlaps %v, found %v", expectedOverlaps, overlaps)
+	}
+}
+
+func TestDistinctRootsKeepsRootsWhichShareAPrefix(t *testing.T) {
+	roots, overlaps := DistinctRoots([]string{"/srv/data", "/srv/data2", "/"})
+	if !reflect.DeepEqual(roots, []string{"/"}) || len(overlaps) != 2 {
+		t.Errorf("Expected everything to be inside /, found %v and %v", roots, overlaps)
+	}
+	roots, _ = DistinctRoots([]string{"/srv/data", "/srv/data2"})
+	if !reflect.DeepEqual(roots, []string{"/srv/data", "/srv/data2"}) {
+		t.Errorf("Expected both roots to be kept, found %v", roots)
+	}
+}
@@ -0,0 +1,47 @@
+package walker
+
+import (
+	"os"
+	"time"
+)
+
+// Stat holds the details of a file which os.FileInfo only exposes through its platform specific
+// Sys method. Files described by other means, such as saved scans, can return a *Stat from Sys, so
+// that they're treated the same way.
+type Stat struct {
+	Dev    uint64
+	Ino    uint64
+	Nlink  uint64
+	Uid    uint32
+	Gid    uint32
+	Blocks int64 // Number of 512-byte blocks allocated.
+	// When the file was last read, which is zero if it isn't known.
+	Atime time.Time
+}
+
+// StatOf returns the details of the file described by info, if the platform provides them.
+func StatOf(info os.FileInfo) (Stat, bool) {
+	if stat, ok := info.Sys().(*Stat); ok {
+		return *stat, true
+	}
+	return sysStat(info)
+}
+
+// DiskUsage returns the number of bytes allocated on disk for the file described by info, which
+// may differ from its apparent size for sparse or compressed files. When the filesystem doesn't
+// report it, the apparent size is returned instead.
+func DiskUsage(info os.FileInfo) int64 {
+	if stat, ok := StatOf(info); ok {
+		// Blocks are always counted in units of 512 bytes, whatever the block size of the
+		// filesystem.
+		return stat.Blocks * 512
+	}
+	return info.Size()
+}
+
+func fileID(info os.FileInfo) (id FileID, nlink uint64, ok bool) {
+	if stat, ok := StatOf(info); ok {
+		return FileID{Dev: stat.Dev, Ino: stat.Ino}, stat.Nlink, true
+	}
+	return FileID{}, 0, false
+}
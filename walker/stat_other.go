@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package walker
+
+import "os"
+
+// The number of blocks and other details of files aren't available on this platform.
+func sysStat(info os.FileInfo) (Stat, bool) {
+	return Stat{}, false
+}
@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package walker
+
+import (
+	"os"
+	"syscall"
+)
+
+func sysStat(info os.FileInfo) (Stat, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Stat{}, false
+	}
+	return Stat{
+		Dev:    uint64(stat.Dev),
+		Ino:    uint64(stat.Ino),
+		Nlink:  uint64(stat.Nlink),
+		Uid:    stat.Uid,
+		Gid:    stat.Gid,
+		Blocks: int64(stat.Blocks),
+		Atime:  atime(stat),
+	}, true
+}
@@ -0,0 +1,83 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkFollowsSymlinks(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	links := map[string]string{
+		"d/to-a":   "../a",           // A directory, which is walked as well.
+		"a/b/loop": "..",             // Back up the tree, which would loop forever.
+		"broken":   "does-not-exist", // Nowhere.
+		"to-file":  "file8.txt",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("Symbolic links are not supported: %s", err)
+		}
+	}
+
+	kinds := map[string]string{}
+	err := Walk(root, Options{Jobs: 2, FollowSymlinks: true}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			kinds[rel] = "link"
+		case IsFollowed(info) && info.IsDir():
+			kinds[rel] = "followed dir"
+		case IsFollowed(info):
+			kinds[rel] = "followed file"
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking tree: %s", err)
+	}
+
+	// a is walked before d, so the link to it isn't walked again, and neither is the loop.
+	expected := map[string]string{
+		"a/b/loop": "link",
+		"broken":   "link",
+		"d/to-a":   "link",
+		"to-file":  "followed file",
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected %v, found %v", expected, kinds)
+	}
+}
+
+func TestWalkFollowsSymlinkToUnwalkedDirectory(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+	outside := createTree(t)
+	defer os.RemoveAll(outside)
+	if err := os.Symlink(filepath.Join(outside, "d"), filepath.Join(root, "elsewhere")); err != nil {
+		t.Skipf("Symbolic links are not supported: %s", err)
+	}
+
+	var found []string
+	err := Walk(root, Options{FollowSymlinks: true}, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(root, path); filepath.Dir(rel) == "elsewhere" || rel == "elsewhere" {
+			found = append(found, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking tree: %s", err)
+	}
+	expected := []string{"elsewhere", "elsewhere/e", "elsewhere/file5.txt"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected the linked directory to be walked, found %v", found)
+	}
+}
@@ -0,0 +1,242 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Options configures how the file tree is walked.
+type Options struct {
+	// Maximum number of directories read concurrently (defaults to the number of CPUs).
+	Jobs int
+	// Whether to include files and directories whose name starts with a dot.
+	IncludeDotFiles bool
+	// Optionally decides which files and directories to leave out, before they are read. It's
+	// called concurrently, and never for the root itself.
+	Skip func(path string, info os.FileInfo) bool
+	// Whether to follow symbolic links, and describe the files they point to instead. Links which
+	// can't be followed, because they're broken, are described as links.
+	FollowSymlinks bool
+}
+
+// DefaultJobs is the number of directories read concurrently, unless specified otherwise.
+var DefaultJobs = runtime.NumCPU()
+
+type entry struct {
+	name string
+	info os.FileInfo
+	// The symbolic link itself, when info describes what it points to.
+	link os.FileInfo
+	err  error
+}
+
+// A directory queued to be read by one of the workers.
+type pending struct {
+	path    string
+	entries []entry
+	err     error
+	done    chan struct{}
+}
+
+type walker struct {
+	opts  Options
+	fn    filepath.WalkFunc
+	queue chan *pending
+	stop  chan struct{}
+	// Directories walked so far, when following symbolic links, so that none is walked twice and
+	// links back up the tree don't loop forever.
+	visited map[FileID]bool
+}
+
+// followed describes the file a symbolic link points to, under the name of the link.
+type followed struct {
+	os.FileInfo
+}
+
+// IsFollowed reports whether info describes the file a symbolic link points to, rather than a file
+// found directly.
+func IsFollowed(info os.FileInfo) bool {
+	_, ok := info.(followed)
+	return ok
+}
+
+// follow returns what a symbolic link points to, or the link itself if it's broken.
+func follow(path string, info os.FileInfo) os.FileInfo {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info
+	}
+	target, err := os.Stat(path)
+	if err != nil {
+		return info
+	}
+	return followed{target}
+}
+
+// Walk walks the file tree rooted at root, calling fn for each file or directory in the tree,
+// including root.
+//
+// Directories are read by a bounded number of workers ahead of time, but fn is always called from
+// a single goroutine, and in the same lexical order as filepath.Walk, so that the results don't
+// depend on how the reads were scheduled. As with filepath.Walk, symbolic links are not followed
+// unless asked for, and fn may return filepath.SkipDir to skip a directory. When links are
+// followed, a directory which has been walked already, whether through another link or because
+// the link points back up the tree, is passed to fn as the link, and not walked again.
+func Walk(root string, opts Options, fn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err == nil && opts.FollowSymlinks {
+		info = follow(root, info)
+	}
+	if err != nil {
+		err = fn(root, nil, err)
+	} else if !info.IsDir() {
+		err = fn(root, info, nil)
+	} else {
+		err = walkRoot(root, info, opts, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walkRoot(root string, info os.FileInfo, opts Options, fn filepath.WalkFunc) error {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+	w := walker{
+		opts:    opts,
+		fn:      fn,
+		queue:   make(chan *pending, jobs),
+		stop:    make(chan struct{}),
+		visited: make(map[FileID]bool),
+	}
+	w.enter(info)
+	for i := 0; i < jobs; i++ {
+		go w.work()
+	}
+	defer close(w.queue)
+	defer close(w.stop)
+	return w.walk(root, info, w.schedule(root))
+}
+
+func (w *walker) work() {
+	for p := range w.queue {
+		select {
+		case <-w.stop:
+			// The walk has finished early, so there is no need to read what's left.
+		default:
+			p.entries, p.err = w.readDir(p.path)
+		}
+		close(p.done)
+	}
+}
+
+// enter reports whether a directory is to be walked, which it always is unless symbolic links are
+// followed and it has been walked already.
+func (w *walker) enter(info os.FileInfo) bool {
+	if !w.opts.FollowSymlinks {
+		return true
+	}
+	id, _, ok := fileID(info)
+	if !ok {
+		return true
+	}
+	if w.visited[id] {
+		return false
+	}
+	w.visited[id] = true
+	return true
+}
+
+func (w *walker) schedule(path string) *pending {
+	p := &pending{path: path, done: make(chan struct{})}
+	w.queue <- p
+	return p
+}
+
+func (w *walker) readDir(path string) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	entries := make([]entry, 0, len(names))
+	for _, name := range names {
+		if !w.opts.IncludeDotFiles && isDotFile(name) {
+			continue
+		}
+		filename := filepath.Join(path, name)
+		info, err := os.Lstat(filename)
+		var link os.FileInfo
+		if err == nil && w.opts.FollowSymlinks {
+			if target := follow(filename, info); IsFollowed(target) {
+				info, link = target, info
+			}
+		}
+		if err == nil && w.opts.Skip != nil && w.opts.Skip(filename, info) {
+			continue
+		}
+		entries = append(entries, entry{name: name, info: info, link: link, err: err})
+	}
+	return entries, nil
+}
+
+func (w *walker) walk(path string, info os.FileInfo, p *pending) error {
+	<-p.done
+	err := w.fn(path, info, p.err)
+	if p.err != nil || err != nil {
+		return err
+	}
+
+	// Queue up all the subdirectories first, so that they can be read while the earlier ones are
+	// being walked.
+	subdirs := make(map[string]*pending)
+	for _, e := range p.entries {
+		if e.err == nil && e.info.IsDir() {
+			subdirs[e.name] = w.schedule(filepath.Join(path, e.name))
+		}
+	}
+
+	for _, e := range p.entries {
+		filename := filepath.Join(path, e.name)
+		if e.err != nil {
+			if err := w.fn(filename, nil, e.err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if e.info.IsDir() && !w.enter(e.info) {
+			info := e.info
+			if e.link != nil {
+				info = e.link
+			}
+			if err := w.fn(filename, info, nil); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if e.info.IsDir() {
+			if err := w.walk(filename, e.info, subdirs[e.name]); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.fn(filename, e.info, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDotFile(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
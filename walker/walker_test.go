@@ -0,0 +1,175 @@
+package walker
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createTree(t *testing.T) string {
+	root, err := ioutil.TempDir("", "forest-walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{
+		"a/b/c/file1.txt",
+		"a/b/file2.txt",
+		"a/file3.txt",
+		"a/.hidden/file4.txt",
+		"d/file5.txt",
+		"d/e/f/g/file6.txt",
+		".file7.txt",
+		"file8.txt",
+	}
+	for i := 0; i < 20; i++ {
+		paths = append(paths, fmt.Sprintf("wide/dir%02d/file.txt", i))
+	}
+	for _, p := range paths {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func collect(t *testing.T, walk func(filepath.WalkFunc) error) []string {
+	var paths []string
+	err := walk(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking tree: %s", err)
+	}
+	return paths
+}
+
+func TestWalkMatchesFilepathWalk(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+
+	expected := collect(t, func(fn filepath.WalkFunc) error {
+		return filepath.Walk(root, fn)
+	})
+	for _, jobs := range []int{1, 2, 8, 64} {
+		paths := collect(t, func(fn filepath.WalkFunc) error {
+			return Walk(root, Options{Jobs: jobs, IncludeDotFiles: true}, fn)
+		})
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Expected walk with %d jobs to match filepath.Walk, found %v", jobs, paths)
+		}
+	}
+}
+
+func TestWalkSkipsDotFiles(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+
+	paths := collect(t, func(fn filepath.WalkFunc) error {
+		return Walk(root, Options{Jobs: 4}, fn)
+	})
+	for _, p := range paths {
+		if isDotFile(filepath.Base(p)) {
+			t.Errorf("Expected dot file %s to be skipped", p)
+		}
+	}
+	if len(paths) == 0 {
+		t.Errorf("Expected other files to be walked")
+	}
+}
+
+func TestWalkSkipDir(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+
+	var paths []string
+	err := Walk(root, Options{Jobs: 4}, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() && info.Name() == "a" {
+			return filepath.SkipDir
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking tree: %s", err)
+	}
+	for _, p := range paths {
+		if rel, _ := filepath.Rel(root, p); rel == "a" || filepath.Dir(rel) == "a" {
+			t.Errorf("Expected directory a to be skipped, found %s", p)
+		}
+	}
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+
+	stop := errors.New("stop")
+	calls := 0
+	err := Walk(root, Options{Jobs: 4}, func(path string, info os.FileInfo, err error) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 3 {
+		t.Errorf("Expected walk to stop after the third call, found %d calls and error %v", calls, err)
+	}
+}
+
+func TestWalkMissingRoot(t *testing.T) {
+	err := Walk("/path/that/does/not/exist", Options{}, func(path string, info os.FileInfo, err error) error {
+		return err
+	})
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected a missing root to be reported, found %v", err)
+	}
+}
+
+func TestDiskUsageOfSparseFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "forest-sparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Truncate(64 * 1024 * 1024); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	info, err := os.Lstat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := DiskUsage(info); usage >= info.Size() {
+		t.Skipf("Filesystem allocated %d bytes for a sparse file, so it doesn't support them", usage)
+	}
+}
+
+func TestWalkSkipsWhatOptionsSkip(t *testing.T) {
+	root := createTree(t)
+	defer os.RemoveAll(root)
+
+	skip := func(path string, info os.FileInfo) bool {
+		return info.Name() == "wide" || filepath.Ext(path) == ".txt"
+	}
+	paths := collect(t, func(fn filepath.WalkFunc) error {
+		return Walk(root, Options{Jobs: 4, Skip: skip}, fn)
+	})
+	for _, p := range paths {
+		if rel, _ := filepath.Rel(root, p); filepath.Ext(p) == ".txt" || rel == "wide" {
+			t.Errorf("Expected %s to be skipped", p)
+		}
+	}
+}